@@ -0,0 +1,61 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import "hash"
+
+// adderFlushEvery is how many adds an Adder counts locally before flushing
+// them to N()
+const adderFlushEvery = 1024
+
+// Adder adds elements to a Filter like Filter.Add, but counts them locally
+// and only adds the count to N() every so often, which spares goroutines
+// adding concurrently from all contending on the one counter. For adding a
+// slice at once, AddAll already updates N() once
+//
+// An Adder is for use by one goroutine; give each goroutine its own. N() is
+// eventually consistent: it lags by up to 1023 elements per Adder until
+// Flush, which should be called when done. Elements are visible to Contains
+// as soon as they are added regardless
+type Adder struct {
+	f       *Filter
+	pending uint64
+}
+
+// NewAdder for adding to f from one goroutine
+func (f *Filter) NewAdder() *Adder {
+	return &Adder{f: f}
+}
+
+// Add a hashable item, v, to the filter
+func (a *Adder) Add(v hash.Hash64) {
+	a.AddRaw(v.Sum64())
+}
+
+// AddRaw adds the item whose 64-bit hash is rawHash, see Filter.AddRaw
+func (a *Adder) AddRaw(rawHash uint64) {
+	a.f.rlockWrite()
+	a.f.add(rawHash)
+	a.f.runlock()
+	a.pending++
+	if a.pending >= adderFlushEvery {
+		a.Flush()
+	}
+}
+
+// Flush the locally counted adds to N() of the filter, checking its
+// rotation threshold, see SetRotationThreshold
+func (a *Adder) Flush() {
+	if a.pending > 0 {
+		n := a.f.n.Add(a.pending)
+		a.f.checkRotation(n, a.pending)
+		a.pending = 0
+	}
+}
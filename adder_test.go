@@ -0,0 +1,62 @@
+package bloomfilter
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAdder(t *testing.T) {
+	f, _ := New(100000, 5)
+	a := f.NewAdder()
+	for i := 0; i < adderFlushEvery+10; i++ {
+		a.Add(hashableUint64(i))
+	}
+	if f.N() != adderFlushEvery {
+		t.Errorf("expected N()=%d before Flush, got %d", adderFlushEvery, f.N())
+	}
+	a.Flush()
+	if f.N() != adderFlushEvery+10 {
+		t.Errorf("expected N()=%d after Flush, got %d", adderFlushEvery+10, f.N())
+	}
+	for i := 0; i < adderFlushEvery+10; i++ {
+		if !f.Contains(hashableUint64(i)) {
+			t.Errorf("definitely does not contain %d, but it should", i)
+		}
+	}
+}
+
+func TestAdderConcurrent(t *testing.T) {
+	f, _ := New(100000, 5)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			a := f.NewAdder()
+			defer a.Flush()
+			for i := 0; i < 1000; i++ {
+				a.Add(hashableUint64(g*1000 + i))
+			}
+		}(g)
+	}
+	wg.Wait()
+	if f.N() != 8*1000 {
+		t.Errorf("expected N()=%d, got %d", 8*1000, f.N())
+	}
+}
+
+func BenchmarkAdderParallelX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(80000, 5)
+	b.ReportAllocs()
+	b.StartTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		a := bf.NewAdder()
+		defer a.Flush()
+		i := 0
+		for pb.Next() {
+			a.Add(hashableUint64(i))
+			i++
+		}
+	})
+}
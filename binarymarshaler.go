@@ -10,15 +10,19 @@
 package bloomfilter
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha512"
 	"encoding/binary"
+	"io"
+	"slices"
 )
 
 // conforms to encoding.BinaryMarshaler
 
 // marshalled binary layout (Little Endian):
 //
+//	 version	1 uint8
 //	 k	1 uint64
 //	 n	1 uint64
 //	 m	1 uint64
@@ -26,50 +30,182 @@ import (
 //	 bits	[(m+63)/64]uint64
 //	 hash	sha384 (384 bits == 48 bytes)
 //
-//	 size = (3 + k + (m+63)/64) * 8 bytes
+//	 size = 1 + (3 + k + (m+63)/64) * 8 + 48 bytes
+//
+// a filter from NewWithSeed with k <= m is marshalled with version 2 instead,
+// which holds its seed in place of the keys:
+//
+//	 version	1 uint8
+//	 k	1 uint64
+//	 n	1 uint64
+//	 m	1 uint64
+//	 seed	1 uint64
+//	 bits	[(m+63)/64]uint64
+//	 hash	sha384 (384 bits == 48 bytes)
+//
+//	 size = 1 + (4 + (m+63)/64) * 8 + 48 bytes
+//
+// releases before the version byte was added wrote the version 1 layout
+// without it; that layout is still read, and is told apart by its header
+// failing to parse as a versioned one
 //
 
-func (f *Filter) marshal() (buf *bytes.Buffer,
+// binaryVersion is the version of the marshalled binary layout
+const binaryVersion uint8 = 1
+
+// binaryVersionSeeded is the version of the layout with a seed for keys
+const binaryVersionSeeded uint8 = 2
+
+// binaryVersionLegacy stands for the unversioned layout, which is only read
+const binaryVersionLegacy uint8 = 0
+
+// binaryHeaderSize is the size of version, k, n and m
+const binaryHeaderSize = 1 + 3*Uint64Bytes
+
+// binaryMaxK and binaryMaxM bound k and m of the filters that can be read,
+// so that a corrupt or hostile header cannot make a stream ask for an
+// impossible allocation before its size is known: 8 MiB of keys and
+// 128 GiB of bits
+const (
+	binaryMaxK = 1 << 20
+	binaryMaxM = 1 << 40
+)
+
+// binaryChunkWords is how many bits words are buffered per read or write
+const binaryChunkWords = 512
+
+// binarySize is the marshalled size of a filter with k keys and m bits
+func binarySize(k, m uint64) uint64 {
+	return binaryHeaderSize + (k+(m+63)/64)*Uint64Bytes + sha512.Size384
+}
+
+// binarySeededSize is the marshalled size of a seeded filter with m bits
+func binarySeededSize(m uint64) uint64 {
+	return binaryHeaderSize + (1+(m+63)/64)*Uint64Bytes + sha512.Size384
+}
+
+// compact reports whether f is marshalled with binaryVersionSeeded; k is
+// bounded by m there so that a header cannot ask for an unbounded number of
+// keys to be regenerated
+func (f *Filter) compact() bool {
+	return f.seeded && f.K() <= f.m
+}
+
+// binarySize is the marshalled size of f
+func (f *Filter) binarySize() uint64 {
+	if f.compact() {
+		return binarySeededSize(f.m)
+	}
+	return binarySize(f.K(), f.m)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeBinary streams f to w in the marshalled binary layout, without
+// building the whole thing in memory; the caller must hold f.lock
+func (f *Filter) writeBinary(w io.Writer) (n int64,
 	hash [sha512.Size384]byte,
 	err error,
 ) {
 
-	debug("write bf k=%d n=%d m=%d\n", f.K(), f.n, f.m)
+	debug("write bf k=%d n=%d m=%d\n", f.K(), f.n.Load(), f.m)
 
-	buf = new(bytes.Buffer)
+	cw := &countingWriter{w: w}
+	h := sha512.New384()
+	bw := bufio.NewWriter(io.MultiWriter(cw, h))
 
-	err = binary.Write(buf, binary.LittleEndian, f.K())
+	version := binaryVersion
+	if f.compact() {
+		version = binaryVersionSeeded
+	}
+	err = binary.Write(bw, binary.LittleEndian, version)
 	if err != nil {
-		return nil, hash, err
+		return cw.n, hash, err
 	}
 
-	err = binary.Write(buf, binary.LittleEndian, f.n)
+	err = binary.Write(bw, binary.LittleEndian, f.K())
 	if err != nil {
-		return nil, hash, err
+		return cw.n, hash, err
 	}
 
-	err = binary.Write(buf, binary.LittleEndian, f.m)
+	err = binary.Write(bw, binary.LittleEndian, f.n.Load())
 	if err != nil {
-		return nil, hash, err
+		return cw.n, hash, err
 	}
 
-	err = binary.Write(buf, binary.LittleEndian, f.keys)
+	err = binary.Write(bw, binary.LittleEndian, f.m)
 	if err != nil {
-		return nil, hash, err
+		return cw.n, hash, err
+	}
+
+	if f.compact() {
+		err = binary.Write(bw, binary.LittleEndian, f.seed)
+	} else {
+		err = binary.Write(bw, binary.LittleEndian, f.keys)
+	}
+	if err != nil {
+		return cw.n, hash, err
 	}
 
-	err = binary.Write(buf, binary.LittleEndian, f.getBits())
+	err = writeBinaryBits(bw, f)
 	if err != nil {
-		return nil, hash, err
+		return cw.n, hash, err
 	}
 
-	hash = sha512.Sum384(buf.Bytes())
-	err = binary.Write(buf, binary.LittleEndian, hash)
-	return buf, hash, err
+	err = bw.Flush()
+	if err != nil {
+		return cw.n, hash, err
+	}
+
+	h.Sum(hash[:0])
+	_, err = cw.Write(hash[:])
+	return cw.n, hash, err
+}
+
+func writeBinaryBits(w io.Writer, f *Filter) (err error) {
+	chunk := make([]byte, 0, binaryChunkWords*Uint64Bytes)
+	for i := range f.bits {
+		chunk = binary.LittleEndian.AppendUint64(chunk, f.bits[i].Load())
+		if len(chunk) == cap(chunk) {
+			_, err = w.Write(chunk)
+			if err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+		}
+	}
+	_, err = w.Write(chunk)
+	return err
+}
+
+func (f *Filter) marshal() (buf *bytes.Buffer,
+	hash [sha512.Size384]byte,
+	err error,
+) {
+	buf = new(bytes.Buffer)
+	buf.Grow(int(f.binarySize()))
+
+	_, hash, err = f.writeBinary(buf)
+	if err != nil {
+		return nil, hash, err
+	}
+	return buf, hash, nil
 }
 
 // MarshalBinary converts a Filter into []bytes
 func (f *Filter) MarshalBinary() (data []byte, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
 	buf, hash, err := f.marshal()
 	if err != nil {
 		return nil, err
@@ -82,3 +218,35 @@ func (f *Filter) MarshalBinary() (data []byte, err error) {
 	data = buf.Bytes()
 	return data, nil
 }
+
+// AppendBinary appends f, exactly as MarshalBinary would encode it, to dst,
+// returning the extended slice, so that a buffer can be reused across calls
+// (Go 1.24's encoding.BinaryAppender)
+func (f *Filter) AppendBinary(dst []byte) ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	start := len(dst)
+	dst = slices.Grow(dst, int(f.binarySize()))
+	compact := f.compact()
+	if compact {
+		dst = append(dst, binaryVersionSeeded)
+	} else {
+		dst = append(dst, binaryVersion)
+	}
+	dst = binary.LittleEndian.AppendUint64(dst, f.K())
+	dst = binary.LittleEndian.AppendUint64(dst, f.n.Load())
+	dst = binary.LittleEndian.AppendUint64(dst, f.m)
+	if compact {
+		dst = binary.LittleEndian.AppendUint64(dst, f.seed)
+	} else {
+		for _, key := range f.keys {
+			dst = binary.LittleEndian.AppendUint64(dst, key)
+		}
+	}
+	for i := range f.bits {
+		dst = binary.LittleEndian.AppendUint64(dst, f.bits[i].Load())
+	}
+	hash := sha512.Sum384(dst[start:])
+	return append(dst, hash[:]...), nil
+}
@@ -0,0 +1,334 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"testing"
+)
+
+func TestMarshalBinaryRoundTrip(t *testing.T) {
+	f, _ := New(1000, 4)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(data)) != binarySize(f.K(), f.M()) {
+		t.Errorf("expected %d byte(s), got %d", binarySize(f.K(), f.M()), len(data))
+	}
+
+	f2 := new(Filter)
+	err = f2.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f2.M() != f.M() || f2.K() != f.K() || f2.N() != f.N() {
+		t.Errorf("expected (m=%d, k=%d, n=%d), got (m=%d, k=%d, n=%d)",
+			f.M(), f.K(), f.N(), f2.M(), f2.K(), f2.N())
+	}
+	if !f.IsCompatible(f2) {
+		t.Error("unmarshalled filter is not compatible with the original")
+	}
+	for _, x := range hashableUint64Values() {
+		if !f2.Contains(x) {
+			t.Error("unmarshalled filter definitely does not contain ", x,
+				", but it should")
+		}
+	}
+}
+
+func TestUnmarshalBinaryCorrupt(t *testing.T) {
+	f, _ := New(1000, 4)
+	f.Add(hashableUint64(7))
+
+	data, _ := f.MarshalBinary()
+	data[len(data)-sha512.Size384-1] ^= 0x01
+
+	err := new(Filter).UnmarshalBinary(data)
+	if err == nil || err.Error() != errHash().Error() {
+		t.Errorf("expected %v, got %v", errHash(), err)
+	}
+}
+
+func TestUnmarshalBinaryTruncated(t *testing.T) {
+	f, _ := New(1000, 4)
+	data, _ := f.MarshalBinary()
+
+	for _, n := range []int{0, 1, binaryHeaderSize, len(data) - 1} {
+		err := new(Filter).UnmarshalBinary(data[:n])
+		if err == nil {
+			t.Errorf("%d byte(s): expected an error", n)
+		}
+	}
+
+	err := new(Filter).UnmarshalBinary(append(data, 0))
+	if err == nil {
+		t.Error("trailing byte: expected an error")
+	}
+}
+
+func TestAppendBinary(t *testing.T) {
+	f, _ := New(1000, 4)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := []byte("prefix")
+	appended, err := f.AppendBinary(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(appended[:len(prefix)], prefix) {
+		t.Error("AppendBinary should keep the existing contents of dst")
+	}
+	if !bytes.Equal(appended[len(prefix):], data) {
+		t.Error("AppendBinary should encode exactly as MarshalBinary does")
+	}
+}
+
+func BenchmarkMarshalBinaryX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = bf.MarshalBinary()
+	}
+}
+
+func BenchmarkAppendBinaryX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	buf, _ := bf.AppendBinary(nil)
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ = bf.AppendBinary(buf[:0])
+	}
+}
+
+// le64 is v in little-endian byte order, spelled out so as not to depend on
+// encoding/binary
+func le64(v uint64) []byte {
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = byte(v >> (8 * i))
+	}
+	return b
+}
+
+func TestBinaryFormatLittleEndian(t *testing.T) {
+	keys := []uint64{0x0102030405060708, 0x1112131415161718}
+	bits := []uint64{0x8000000000000001, 0x0000000f00000000}
+
+	data := []byte{1}                 // version
+	data = append(data, le64(2)...)   // k
+	data = append(data, le64(3)...)   // n
+	data = append(data, le64(100)...) // m
+	for _, v := range append(keys, bits...) {
+		data = append(data, le64(v)...)
+	}
+	hash := sha512.Sum384(data)
+	data = append(data, hash[:]...)
+
+	if !bytes.Equal(data[1:9], []byte{2, 0, 0, 0, 0, 0, 0, 0}) ||
+		!bytes.Equal(data[25:33], []byte{8, 7, 6, 5, 4, 3, 2, 1}) {
+		t.Fatal("le64 is not little-endian")
+	}
+
+	f := new(Filter)
+	if err := f.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if f.M() != 100 || f.K() != 2 || f.N() != 3 {
+		t.Errorf("expected (m=100, k=2, n=3), got (m=%d, k=%d, n=%d)", f.M(), f.K(), f.N())
+	}
+	for i, key := range f.Keys() {
+		if key != keys[i] {
+			t.Errorf("keys[%d]: expected %#x, got %#x", i, keys[i], key)
+		}
+	}
+	for i, word := range f.BitSet() {
+		if word != bits[i] {
+			t.Errorf("bits[%d]: expected %#x, got %#x", i, bits[i], word)
+		}
+	}
+
+	out, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Error("MarshalBinary should reproduce the hand-assembled bytes")
+	}
+}
+
+func TestMarshalBinarySeeded(t *testing.T) {
+	f, _ := NewWithSeed(1000, 4, 42)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != binaryVersionSeeded {
+		t.Errorf("expected version %d, got %d", binaryVersionSeeded, data[0])
+	}
+	if uint64(len(data)) != binarySeededSize(f.M()) ||
+		uint64(len(data)) >= binarySize(f.K(), f.M()) {
+		t.Errorf("expected %d byte(s), got %d", binarySeededSize(f.M()), len(data))
+	}
+	appended, _ := f.AppendBinary(nil)
+	if !bytes.Equal(appended, data) {
+		t.Error("AppendBinary should match MarshalBinary for a seeded filter")
+	}
+
+	f2 := new(Filter)
+	err = f2.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f2.M() != f.M() || f2.K() != f.K() || f2.N() != f.N() {
+		t.Errorf("expected (m=%d, k=%d, n=%d), got (m=%d, k=%d, n=%d)",
+			f.M(), f.K(), f.N(), f2.M(), f2.K(), f2.N())
+	}
+	if !f.IsCompatible(f2) {
+		t.Error("unmarshalled seeded filter is not compatible with the original")
+	}
+	for _, x := range hashableUint64Values() {
+		if !f2.Contains(x) {
+			t.Error("unmarshalled filter definitely does not contain ", x,
+				", but it should")
+		}
+	}
+
+	out, _ := f2.MarshalBinary()
+	if !bytes.Equal(out, data) {
+		t.Error("a seeded filter should stay compact across a round trip")
+	}
+
+	var b bytes.Buffer
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	f3 := new(Filter)
+	if _, err := f3.ReadFrom(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsCompatible(f3) || f3.N() != f.N() {
+		t.Error("seeded filter did not survive WriteTo and ReadFrom")
+	}
+}
+
+func TestMarshalBinaryExplicitKeys(t *testing.T) {
+	keys, _ := NewWithSeed(1000, 4, 42)
+	f, _ := NewWithKeys(1000, keys.Keys())
+	f.Add(hashableUint64(7))
+
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != binaryVersion || uint64(len(data)) != binarySize(f.K(), f.M()) {
+		t.Errorf("expected the full version %d layout, got version %d and %d byte(s)",
+			binaryVersion, data[0], len(data))
+	}
+
+	f2 := new(Filter)
+	if err := f2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsCompatible(f2) || !f2.Contains(hashableUint64(7)) {
+		t.Error("explicit-key filter did not round trip")
+	}
+}
+
+func TestUnmarshalBinarySeededTooManyKeys(t *testing.T) {
+	data := []byte{binaryVersionSeeded}
+	data = append(data, le64(1000)...) // k
+	data = append(data, le64(0)...)    // n
+	data = append(data, le64(64)...)   // m
+	data = append(data, le64(42)...)   // seed
+	data = append(data, le64(0)...)    // bits
+	hash := sha512.Sum384(data)
+	data = append(data, hash[:]...)
+
+	err := new(Filter).UnmarshalBinary(data)
+	if err == nil || err.Error() != errSize().Error() {
+		t.Errorf("expected %v, got %v", errSize(), err)
+	}
+}
+
+// legacyBinary assembles the unversioned layout written before the version
+// byte was added
+func legacyBinary(k, n, m uint64) (data []byte, keys, bits []uint64) {
+	data = append(data, le64(k)...)
+	data = append(data, le64(n)...)
+	data = append(data, le64(m)...)
+	for i := uint64(0); i < k; i++ {
+		keys = append(keys, 0x0102030405060708*(i+1))
+		data = append(data, le64(keys[i])...)
+	}
+	for i := uint64(0); i < (m+63)/64; i++ {
+		bits = append(bits, 0x0000000700000001<<i)
+		data = append(data, le64(bits[i])...)
+	}
+	hash := sha512.Sum384(data)
+	return append(data, hash[:]...), keys, bits
+}
+
+func TestUnmarshalBinaryLegacy(t *testing.T) {
+	for _, k := range []uint64{1, 2, 4} {
+		data, keys, bits := legacyBinary(k, 3, 100)
+		if uint64(len(data)) != binarySize(k, 100)-1 {
+			t.Fatalf("k=%d: expected %d byte(s), got %d", k, binarySize(k, 100)-1, len(data))
+		}
+
+		f := new(Filter)
+		if err := f.UnmarshalBinary(data); err != nil {
+			t.Fatalf("k=%d: %v", k, err)
+		}
+		g := new(Filter)
+		if _, err := g.ReadFrom(bytes.NewReader(data)); err != nil {
+			t.Fatalf("k=%d: ReadFrom: %v", k, err)
+		}
+
+		for _, f := range []*Filter{f, g} {
+			if f.M() != 100 || f.K() != k || f.N() != 3 {
+				t.Errorf("expected (m=100, k=%d, n=3), got (m=%d, k=%d, n=%d)",
+					k, f.M(), f.K(), f.N())
+			}
+			for i, key := range f.Keys() {
+				if key != keys[i] {
+					t.Errorf("k=%d: keys[%d]: expected %#x, got %#x", k, i, keys[i], key)
+				}
+			}
+			for i, word := range f.BitSet() {
+				if word != bits[i] {
+					t.Errorf("k=%d: bits[%d]: expected %#x, got %#x", k, i, bits[i], word)
+				}
+			}
+		}
+	}
+}
+
+func TestUnmarshalBinaryUnsupportedVersion(t *testing.T) {
+	f, _ := New(1000, 4)
+	data, _ := f.MarshalBinary()
+	data[0] = 3
+
+	err := new(Filter).UnmarshalBinary(data)
+	if err == nil || err.Error() != errVersion(3).Error() {
+		t.Errorf("expected %v, got %v", errVersion(3), err)
+	}
+}
@@ -18,50 +18,125 @@ import (
 	"sync/atomic"
 )
 
-func unmarshalBinaryHeader(r io.Reader) (k, n, m uint64, err error) {
-	err = binary.Read(r, binary.LittleEndian, &k)
-	if err != nil {
-		return k, n, m, err
-	}
+type countingReader struct {
+	r io.Reader
+	n int64
+}
 
-	if k < KMin {
-		return k, n, m, errK()
-	}
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
 
-	err = binary.Read(r, binary.LittleEndian, &n)
+// unmarshalBinaryHeader reads the header from r, falling back to the
+// unversioned layout of binaryVersionLegacy when it is not a valid
+// versioned header but is a valid legacy one; that header is a byte
+// shorter, so the byte read past it, the start of the keys, is returned as
+// rest for the caller to read again
+func unmarshalBinaryHeader(r io.Reader) (version uint8, k, n, m uint64,
+	rest []byte, err error,
+) {
+	header := make([]byte, binaryHeaderSize)
+	_, err = io.ReadFull(r, header)
 	if err != nil {
-		return k, n, m, err
+		return version, k, n, m, nil, err
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &m)
+	version, k, n, m, err = parseVersionedHeader(header)
 	if err != nil {
-		return k, n, m, err
-	}
-
-	if m < MMin {
-		return k, n, m, errM()
+		lk, ln, lm := parseBinaryHeader(header)
+		if checkBinaryHeader(lk, lm) != nil {
+			return version, k, n, m, nil, err
+		}
+		version, k, n, m = binaryVersionLegacy, lk, ln, lm
+		rest = header[binaryHeaderSize-1:]
 	}
 
 	debug("read bf k=%d n=%d m=%d\n", k, n, m)
 
-	return k, n, m, err
+	return version, k, n, m, rest, nil
 }
 
-func unmarshalBinaryBits(r io.Reader, m uint64) (bits []atomic.Uint64, err error) {
-	bits, err = newBits(m)
-	if err != nil {
-		return
+// parseVersionedHeader decodes and checks header as a versioned header
+func parseVersionedHeader(header []byte) (version uint8, k, n, m uint64,
+	err error,
+) {
+	version = header[0]
+	k, n, m = parseBinaryHeader(header[1:])
+	if version != binaryVersion && version != binaryVersionSeeded {
+		return version, k, n, m, errVersion(version)
+	}
+	return version, k, n, m, checkBinaryHeader(k, m)
+}
+
+// parseBinaryHeader decodes k, n and m from the start of b
+func parseBinaryHeader(b []byte) (k, n, m uint64) {
+	return binary.LittleEndian.Uint64(b),
+		binary.LittleEndian.Uint64(b[Uint64Bytes:]),
+		binary.LittleEndian.Uint64(b[2*Uint64Bytes:])
+}
+
+// checkBinaryHeader checks k and m from a header, before anything is
+// allocated from them
+func checkBinaryHeader(k, m uint64) error {
+	switch {
+	case k < KMin:
+		return errK()
+	case m < MMin:
+		return errM()
+	case k > binaryMaxK || m > binaryMaxM:
+		return errSize()
 	}
-	for i, _ := range bits {
-		var word uint64
-		err = binary.Read(r, binary.LittleEndian, &word)
+	return nil
+}
+
+// unmarshalBinaryBits fills bits from r, a chunk at a time so that r is
+// never read past the end of the bits
+func unmarshalBinaryBits(r io.Reader, bits []atomic.Uint64) (err error) {
+	chunk := make([]byte, min(len(bits), binaryChunkWords)*Uint64Bytes)
+	for i := 0; i < len(bits); i += binaryChunkWords {
+		words := bits[i:min(i+binaryChunkWords, len(bits))]
+		_, err = io.ReadFull(r, chunk[:len(words)*Uint64Bytes])
 		if err != nil {
-			return
+			return err
+		}
+		for j := range words {
+			words[j].Store(binary.LittleEndian.Uint64(chunk[j*Uint64Bytes:]))
 		}
-		bits[i].Store(word)
 	}
-	return
+	return nil
+}
 
+// binaryTrustedWords is how many bits words a stream is trusted to hold
+// before any have been read; past it, bits grow as the stream turns out to
+// hold them, so a header that lies about m costs at most twice the memory
+// of the words actually read
+const binaryTrustedWords = 1 << 20
+
+// unmarshalBinaryBitsGrowing reads the (m+63)/64 bits words of a stream
+// into a new slice, grown by doubling from binaryTrustedWords
+func unmarshalBinaryBitsGrowing(r io.Reader, m uint64) (
+	bits []atomic.Uint64, err error,
+) {
+	words := (m + 63) / 64
+	bits = make([]atomic.Uint64, min(words, binaryTrustedWords))
+	filled := 0
+	for {
+		err = unmarshalBinaryBits(r, bits[filled:])
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(bits)) == words {
+			return bits, nil
+		}
+		filled = len(bits)
+		grown := make([]atomic.Uint64, min(words, 2*uint64(len(bits))))
+		for i := range bits {
+			grown[i].Store(bits[i].Load())
+		}
+		bits = grown
+	}
 }
 
 func unmarshalBinaryKeys(r io.Reader, k uint64) (keys []uint64, err error) {
@@ -70,51 +145,137 @@ func unmarshalBinaryKeys(r io.Reader, k uint64) (keys []uint64, err error) {
 	return keys, err
 }
 
-func checkBinaryHash(r io.Reader, data []byte) (err error) {
+func checkBinaryHash(r io.Reader, actualHash []byte) (err error) {
 	expectedHash := make([]byte, sha512.Size384)
-	err = binary.Read(r, binary.LittleEndian, expectedHash)
+	_, err = io.ReadFull(r, expectedHash)
 	if err != nil {
 		return err
 	}
 
-	actualHash := sha512.Sum384(data[:len(data)-sha512.Size384])
-
-	if !hmac.Equal(expectedHash, actualHash[:]) {
+	if !hmac.Equal(expectedHash, actualHash) {
 		debug("bloomfilter.UnmarshalBinary() sha384 hash failed:"+
 			" actual %v  expected %v", actualHash, expectedHash)
 		return errHash()
 	}
 
-	debug("bloomfilter.UnmarshalBinary() successfully read"+
-		" %d byte(s), sha384 %v", len(data), actualHash)
 	return nil
 }
 
+// readBinary streams a filter in the marshalled binary layout from r
+//
+// bits is reused for the result when it is the right length, in which case
+// its contents are unspecified on error
+func readBinary(r io.Reader, bits []atomic.Uint64) (
+	f *Filter, read int64, err error,
+) {
+	cr := &countingReader{r: r}
+	h := sha512.New384()
+	tr := io.TeeReader(cr, h)
+
+	version, k, n, m, rest, err := unmarshalBinaryHeader(tr)
+	if err != nil {
+		return nil, cr.n, err
+	}
+	if len(rest) > 0 {
+		tr = io.MultiReader(bytes.NewReader(rest), tr)
+	}
+
+	var keys []uint64
+	var seed uint64
+	if version == binaryVersionSeeded {
+		if k > m {
+			return nil, cr.n, errSize()
+		}
+		err = binary.Read(tr, binary.LittleEndian, &seed)
+		if err == nil {
+			keys = newSeededKeys(k, seed)
+		}
+	} else {
+		keys, err = unmarshalBinaryKeys(tr, k)
+	}
+	if err != nil {
+		return nil, cr.n, err
+	}
+
+	if uint64(len(bits)) == (m+63)/64 {
+		err = unmarshalBinaryBits(tr, bits)
+	} else {
+		bits, err = unmarshalBinaryBitsGrowing(tr, m)
+	}
+	if err != nil {
+		return nil, cr.n, err
+	}
+
+	err = checkBinaryHash(cr, h.Sum(nil))
+	if err != nil {
+		return nil, cr.n, err
+	}
+
+	debug("bloomfilter.readBinary() successfully read %d byte(s)", cr.n)
+
+	f = &Filter{m: m, keys: keys, bits: bits}
+	f.seed, f.seeded = seed, version == binaryVersionSeeded
+	f.maskTail()
+	f.n.Store(n)
+	return f, cr.n, nil
+}
+
+// checkBinarySize rejects data that is not exactly as long as its header
+// says, before anything is allocated from the header, returning m
+func checkBinarySize(data []byte) (m uint64, err error) {
+	if len(data) < binaryHeaderSize+sha512.Size384 {
+		return 0, errSize()
+	}
+	version, k, _, m, _, err := unmarshalBinaryHeader(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	// k and m are bounded by the header checks, so these cannot overflow
+	size := binarySeededSize(m)
+	switch version {
+	case binaryVersion:
+		size = binarySize(k, m)
+	case binaryVersionLegacy:
+		size = binarySize(k, m) - 1
+	}
+	if size != uint64(len(data)) {
+		if version == binaryVersionLegacy {
+			// more likely a versioned header gone wrong than a legacy one
+			_, _, _, _, err = parseVersionedHeader(data)
+			return 0, err
+		}
+		return 0, errSize()
+	}
+	return m, nil
+}
+
 // UnmarshalBinary converts []bytes into a Filter
 // conforms to encoding.BinaryUnmarshaler
 func (f *Filter) UnmarshalBinary(data []byte) (err error) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	buf := bytes.NewBuffer(data)
-
-	var k uint64
-	var n uint64
-	k, n, f.m, err = unmarshalBinaryHeader(buf)
+	m, err := checkBinarySize(data)
 	if err != nil {
 		return err
 	}
-	f.n.Store(n)
-
-	f.keys, err = unmarshalBinaryKeys(buf, k)
+	// data is known to hold all of the bits, so they need not be grown
+	bits, err := newBits(m)
 	if err != nil {
 		return err
 	}
 
-	f.bits, err = unmarshalBinaryBits(buf, f.m)
+	f2, _, err := readBinary(bytes.NewReader(data), bits)
 	if err != nil {
 		return err
 	}
 
-	return checkBinaryHash(buf, data)
+	f.m = f2.m
+	f.n.Store(f2.n.Load())
+	f.keys = f2.keys
+	f.seed, f.seeded = f2.seed, f2.seeded
+	f.pooled = false
+	f.bits = f2.bits
+	f.shared.Store(false)
+	return nil
 }
@@ -0,0 +1,84 @@
+package bloomfilter
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBlockedFilter(t *testing.T) {
+	const n = 10000
+	f, err := NewBlocked(OptimalM(n, 0.01), OptimalK(OptimalM(n, 0.01), n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.M()%blockBits != 0 || f.M() < OptimalM(n, 0.01) {
+		t.Errorf("expected M() to round up to whole blocks, got %d", f.M())
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	values := make([]hashableUint64, n)
+	for i := range values {
+		values[i] = hashableUint64(rng.Uint64())
+		f.Add(values[i])
+	}
+	if f.N() != n {
+		t.Errorf("expected N()=%d, got %d", n, f.N())
+	}
+	for _, v := range values {
+		if !f.Contains(v) {
+			t.Fatalf("definitely does not contain %d, but it should", v)
+		}
+	}
+
+	// somewhat worse than the 1% of an unblocked filter
+	falsePositives := 0
+	const probes = 100000
+	for i := 0; i < probes; i++ {
+		if f.Contains(hashableUint64(rng.Uint64())) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / probes; rate > 0.02 {
+		t.Errorf("expected a false positive rate under 2%%, got %g", rate)
+	}
+}
+
+func TestNewBlockedInvalid(t *testing.T) {
+	if _, err := NewBlocked(1, 5); err == nil {
+		t.Error("expected an error for m < MMin")
+	}
+	if _, err := NewBlocked(1000, 0); err == nil {
+		t.Error("expected an error for k < KMin")
+	}
+}
+
+// randomRawHashes is 1024 random hashes to look up, so that lookups of a
+// large filter miss the caches
+func randomRawHashes() []uint64 {
+	rng := rand.New(rand.NewSource(1))
+	hashes := make([]uint64, 1024)
+	for i := range hashes {
+		hashes[i] = rng.Uint64()
+	}
+	return hashes
+}
+
+func BenchmarkContainsRaw100MX7(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(100_000_000, 7)
+	hashes := randomRawHashes()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		bf.ContainsRaw(hashes[i&1023])
+	}
+}
+
+func BenchmarkContainsRawBlocked100MX7(b *testing.B) {
+	b.StopTimer()
+	bf, _ := NewBlocked(100_000_000, 7)
+	hashes := randomRawHashes()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		bf.ContainsRaw(hashes[i&1023])
+	}
+}
@@ -10,9 +10,13 @@
 package bloomfilter
 
 import (
+	"context"
 	"hash"
+	"math"
+	"math/bits"
 	"sync"
 	"sync/atomic"
+	"unsafe"
 )
 
 // Filter is an opaque Bloom filter type
@@ -23,30 +27,184 @@ type Filter struct {
 	// "Lock" is for actions (including reads) that do require a consistent view
 	// of the bits
 	lock sync.RWMutex
-	bits []atomic.Uint64 // mutable
+	bits []atomic.Uint64 // mutable; bits past m in the last word are always clear
 	keys []uint64        // immutable after init
 	m    uint64          // number of bits the "bits" field should recognize; immutable after init
 	n    atomic.Uint64   // number of inserted elements; mutable
+
+	unlocked  bool         // Add, AddC and Contains skip lock, see NewUnsafe; immutable after init
+	positions PositionFunc // nil for the default key-XOR positions; immutable after init
+	seed      uint64       // source of keys when seeded; immutable after init
+	seeded    bool         // keys derive from seed, see NewWithSeed; immutable after init
+	pooled    bool         // made by GetPooled, so PutPooled may reuse it; cleared by unmarshalling
+
+	rotation atomic.Pointer[rotation] // nil until SetRotationThreshold
+	shared   atomic.Bool              // bits may be shared with a Snapshot
+}
+
+// PositionFunc derives the k bit positions of an item from its raw 64-bit
+// hash, in place of the default of XORing rawHash with each key; positions
+// are reduced modulo m by the filter
+type PositionFunc func(rawHash uint64, k int) []uint64
+
+// rlock is lock.RLock, unless f was built by NewUnsafe
+func (f *Filter) rlock() {
+	if !f.unlocked {
+		f.lock.RLock()
+	}
+}
+
+// runlock undoes rlock
+func (f *Filter) runlock() {
+	if !f.unlocked {
+		f.lock.RUnlock()
+	}
+}
+
+// rlockWrite is rlock for actions that write to f.bits, taken once f no
+// longer shares them with a Snapshot; undo it with runlock
+func (f *Filter) rlockWrite() {
+	f.rlock()
+	for f.shared.Load() {
+		f.runlock()
+		f.unshare()
+		f.rlock()
+	}
+}
+
+// lockRWrite is rlockWrite that always locks, even when f was built by
+// NewUnsafe; undo it with lock.RUnlock
+func (f *Filter) lockRWrite() {
+	f.lock.RLock()
+	for f.shared.Load() {
+		f.lock.RUnlock()
+		f.unshare()
+		f.lock.RLock()
+	}
+}
+
+// unshare gives f a copy of the bits it shares with a Snapshot, if any
+func (f *Filter) unshare() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.unshareLocked()
+}
+
+// unshareLocked is unshare for a caller that holds f.lock exclusively
+func (f *Filter) unshareLocked() {
+	if !f.shared.Load() {
+		return
+	}
+	bits := make([]atomic.Uint64, len(f.bits))
+	for i := range f.bits {
+		bits[i].Store(f.bits[i].Load())
+	}
+	f.bits = bits
+	f.shared.Store(false)
+}
+
+// lockedFirst reports whether a is locked before b by operations that lock
+// two filters; ordering them by address means two such operations on the
+// same pair, whichever way round, cannot deadlock, nor can either of them
+// with a writer waiting on the lock taken second
+func lockedFirst(a, b *Filter) bool {
+	return uintptr(unsafe.Pointer(a)) < uintptr(unsafe.Pointer(b))
+}
+
+// rlockPair read-locks f and f2 in lockedFirst order, f only once when f2
+// is f, returning the matching unlock
+func rlockPair(f, f2 *Filter) (runlock func()) {
+	if f == f2 {
+		f.lock.RLock()
+		return f.lock.RUnlock
+	}
+	if !lockedFirst(f, f2) {
+		f, f2 = f2, f
+	}
+	f.lock.RLock()
+	f2.lock.RLock()
+	return func() {
+		f2.lock.RUnlock()
+		f.lock.RUnlock()
+	}
+}
+
+// lockRWritePair is lockRWrite of f and a read lock of f2, taken in
+// lockedFirst order, f only once when f2 is f, returning the matching unlock
+func lockRWritePair(f, f2 *Filter) (runlock func()) {
+	if f == f2 {
+		f.lockRWrite()
+		return f.lock.RUnlock
+	}
+	if lockedFirst(f, f2) {
+		f.lockRWrite()
+		f2.lock.RLock()
+	} else {
+		f2.lock.RLock()
+		f.lockRWrite()
+	}
+	return func() {
+		f2.lock.RUnlock()
+		f.lock.RUnlock()
+	}
+}
+
+// saturatingAdd is a + b, or math.MaxUint64 when that overflows
+func saturatingAdd(a, b uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return math.MaxUint64
+	}
+	return sum
+}
+
+// addN atomically adds delta to the element count n, saturating
+func addN(n *atomic.Uint64, delta uint64) {
+	for {
+		old := n.Load()
+		if n.CompareAndSwap(old, saturatingAdd(old, delta)) {
+			return
+		}
+	}
 }
 
 func (f *Filter) getBits() []uint64 {
 	out := make([]uint64, len(f.bits))
-	for i, v := range f.bits {
-		out[i] = v.Load()
+	for i := range f.bits {
+		out[i] = f.bits[i].Load()
 	}
 	return out
 }
 
+// setBits overwrites the bits of f with b; the caller must hold f.lock
+// exclusively
 func (f *Filter) setBits(b []uint64) {
+	f.unshareLocked()
 	for i, v := range b[0:min(len(b), len(f.bits))] {
 		f.bits[i].Store(v)
 	}
+	f.maskTail()
+}
+
+// maskTail clears the bits past m in the last word, which are never set by
+// adding elements but could be by importing bits; keeping them clear keeps
+// Equal and the marshalled forms of equivalent filters the same
+func (f *Filter) maskTail() {
+	if tail := f.m % 64; tail != 0 && len(f.bits) > 0 {
+		f.bits[len(f.bits)-1].And(1<<tail - 1)
+	}
 }
 
 // Hashable -> hashes
 func (f *Filter) hash(v hash.Hash64) []uint64 {
+	if f.m == 0 {
+		return nil
+	}
 	rawHash := v.Sum64()
 	n := len(f.keys)
+	if f.positions != nil {
+		return f.positions(rawHash, n)
+	}
 	hashes := make([]uint64, n)
 	for i := 0; i < n; i++ {
 		hashes[i] = rawHash ^ f.keys[i]
@@ -64,17 +222,117 @@ func (f *Filter) K() uint64 {
 	return uint64(len(f.keys))
 }
 
-// Add a hashable item, v, to the filter
-func (f *Filter) Add(v hash.Hash64) {
-	h := f.hash(v)
+// Keys is a copy of the keys, for building compatible filters elsewhere with
+// NewWithKeys
+func (f *Filter) Keys() []uint64 {
 	f.lock.RLock()
 	defer f.lock.RUnlock()
-	for _, i := range h {
+	keys := make([]uint64, len(f.keys))
+	copy(keys, f.keys)
+	return keys
+}
+
+// BitSet is a copy of the (m+63)/64 words of bits, for use with other bit
+// manipulation code; see NewFromBitSet
+func (f *Filter) BitSet() []uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.getBits()
+}
+
+// SetBits is an iterator over the indices of the set bits, in increasing
+// order, for use as
+//
+//	for i := range f.SetBits { ... }
+//
+// It holds the read lock while iterating, so the loop body must not call
+// methods of f that take the write lock, such as Copy or Clear
+func (f *Filter) SetBits(yield func(uint64) bool) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	for w := range f.bits {
+		word := f.bits[w].Load()
+		for word != 0 {
+			i := uint64(w)<<6 + uint64(bits.TrailingZeros64(word))
+			if !yield(i) {
+				return
+			}
+			word &= word - 1
+		}
+	}
+}
+
+// Add a hashable item, v, to the filter
+func (f *Filter) Add(v hash.Hash64) {
+	f.AddRaw(v.Sum64())
+}
+
+// AddRaw adds the item whose 64-bit hash is rawHash to the filter, which
+// saves wrapping an already-computed hash in a hash.Hash64
+//
+// rawHash must come from a well-distributed hash function, as the bit
+// positions are derived from it directly
+func (f *Filter) AddRaw(rawHash uint64) {
+	f.rlockWrite()
+	f.add(rawHash)
+	n := f.n.Add(1)
+	f.runlock()
+	f.checkRotation(n, 1)
+}
+
+// add sets the bits of rawHash without counting it; the caller must hold
+// f.lock
+func (f *Filter) add(rawHash uint64) {
+	if f.m == 0 {
+		// only a zero Filter, never a constructed one, has no bits
+		return
+	}
+	if f.positions != nil {
+		for _, i := range f.positions(rawHash, len(f.keys)) {
+			i %= f.m
+			f.bits[i>>6].Or(1 << uint(i&0x3f))
+		}
+		return
+	}
+	for _, key := range f.keys {
 		// f.setBit(i)
-		i %= f.m
+		i := (rawHash ^ key) % f.m
 		f.bits[i>>6].Or(1 << uint(i&0x3f))
 	}
-	f.n.Add(1)
+}
+
+// AddAll adds every hashable item in items to the filter, taking the lock
+// and updating N() once for the whole batch
+func (f *Filter) AddAll(items []hash.Hash64) {
+	f.lockRWrite()
+	for _, v := range items {
+		f.add(v.Sum64())
+	}
+	n := f.n.Add(uint64(len(items)))
+	f.lock.RUnlock()
+	f.checkRotation(n, uint64(len(items)))
+}
+
+// addAllContextChunk is how many items AddAllContext adds between checks
+// for cancellation
+const addAllContextChunk = 4096
+
+// AddAllContext adds every hashable item in items to the filter, like
+// AddAll, checking ctx every few thousand items
+//
+// On cancellation it returns how many items, a prefix of items, were added,
+// along with ctx.Err(); the filter is valid, just partially populated
+func (f *Filter) AddAllContext(ctx context.Context, items []hash.Hash64) (added int, err error) {
+	for added < len(items) {
+		err = ctx.Err()
+		if err != nil {
+			return added, err
+		}
+		end := min(added+addAllContextChunk, len(items))
+		f.AddAll(items[added:end])
+		added = end
+	}
+	return added, nil
 }
 
 // AddC adds a hashable item, v, to the filter, testing for its presence
@@ -83,34 +341,283 @@ func (f *Filter) Add(v hash.Hash64) {
 // true:  f maybe contains value v
 func (f *Filter) AddC(v hash.Hash64) bool {
 	h := f.hash(v)
-	f.lock.RLock()
-	f.lock.RUnlock()
+	f.rlockWrite()
 	r := uint64(1)
 	for _, i := range h {
 		i %= f.m
 		r &= (f.bits[i>>6].Load() >> uint(i&0x3f)) & 1
 		f.bits[i>>6].Or(1 << uint(i&0x3f))
 	}
-	f.n.Add(1)
-	return uint64ToBool(r)
+	n := f.n.Add(1)
+	f.runlock()
+	f.checkRotation(n, 1)
+	// a zero Filter has no positions to find set
+	return len(h) > 0 && uint64ToBool(r)
+}
+
+// AddNew adds a hashable item, v, to the filter, reporting whether that
+// set any bit that was not already set, from the previous values of the
+// words it updates
+// false: f maybe contained value v already
+// true:  f definitely did not contain value v
+//
+// Concurrent AddNews of the same item may both report true, when each sets
+// some of its bits first
+func (f *Filter) AddNew(v hash.Hash64) bool {
+	h := f.hash(v)
+	f.rlockWrite()
+	flipped := uint64(0)
+	for _, i := range h {
+		i %= f.m
+		mask := uint64(1) << uint(i&0x3f)
+		flipped |= ^f.bits[i>>6].Or(mask) & mask
+	}
+	n := f.n.Add(1)
+	f.runlock()
+	f.checkRotation(n, 1)
+	return flipped != 0
+}
+
+// TestAndAdd adds a hashable item, v, to the filter, like AddC, but such
+// that out of any number of concurrent TestAndAdds of the same item, at most
+// one (normally exactly one) reports it as new
+// false: f maybe contained value v already
+// true:  f definitely did not contain value v, and this call added it
+//
+// It sets all but one of the missing bits of v, then sets the last, highest
+// one with a compare-and-swap, and only the caller whose swap completes the
+// item reports true; when the swap finds that bit set by another, it starts
+// over. That costs up to twice the atomic operations of AddC, and retries
+// under contention. If unrelated items concurrently set the bit a caller
+// swaps for, every caller may report false, as for a false positive
+func (f *Filter) TestAndAdd(v hash.Hash64) bool {
+	h := f.hash(v)
+	for j := range h {
+		h[j] %= f.m
+	}
+	f.rlockWrite()
+	n := f.n.Add(1)
+	added := f.testAndAdd(h)
+	f.runlock()
+	f.checkRotation(n, 1)
+	return added
+}
+
+// testAndAdd is TestAndAdd of the positions h, already reduced mod m; the
+// caller must hold f.lock
+func (f *Filter) testAndAdd(h []uint64) bool {
+	for {
+		last, missing := uint64(0), false
+		for _, i := range h {
+			if f.bits[i>>6].Load()&(1<<uint(i&0x3f)) != 0 {
+				continue
+			}
+			switch {
+			case !missing:
+				last, missing = i, true
+			case i == last:
+			case i > last:
+				f.bits[last>>6].Or(1 << uint(last&0x3f))
+				last = i
+			default:
+				f.bits[i>>6].Or(1 << uint(i&0x3f))
+			}
+		}
+		if !missing {
+			return false
+		}
+		if f.casBit(last) {
+			return true
+		}
+	}
+}
+
+// casBit sets bit i if it is clear, reporting whether it did; the caller
+// must hold f.lock
+func (f *Filter) casBit(i uint64) bool {
+	word, mask := &f.bits[i>>6], uint64(1)<<uint(i&0x3f)
+	for {
+		old := word.Load()
+		if old&mask != 0 {
+			return false
+		}
+		if word.CompareAndSwap(old, old|mask) {
+			return true
+		}
+	}
+}
+
+// ContainsThenAdd tests if f contains a hashable item, v, then adds it, as
+// one step under the write Lock, so that no concurrent change to f comes
+// between the two; AddC instead tests and sets each bit in turn under the
+// read lock, so a concurrent Add can change its answer midway
+// false: f definitely did not contain value v
+// true:  f maybe contained value v already
+//
+// The Lock excludes every other use of f while it is held, even for a
+// filter from NewUnsafe, so this is slower than AddC under contention
+func (f *Filter) ContainsThenAdd(v hash.Hash64) (wasPresent bool) {
+	rawHash := v.Sum64()
+	f.lock.Lock()
+	f.unshareLocked()
+	wasPresent = f.contains(rawHash)
+	f.add(rawHash)
+	n := f.n.Add(1)
+	f.lock.Unlock()
+	f.checkRotation(n, 1)
+	return wasPresent
 }
 
 // Contains tests if f contains v
 // false: f definitely does not contain value v
 // true:  f maybe contains value v
 func (f *Filter) Contains(v hash.Hash64) bool {
+	return f.ContainsRaw(v.Sum64())
+}
+
+// ContainsRaw tests if f contains the item whose 64-bit hash is rawHash,
+// see AddRaw
+// false: f definitely does not contain the item
+// true:  f maybe contains the item
+func (f *Filter) ContainsRaw(rawHash uint64) bool {
+	f.rlock()
+	defer f.runlock()
+	return f.contains(rawHash)
+}
+
+// AddSalted adds a hashable item, v, to the filter within the namespace
+// salt, by XORing salt into its raw hash: AddRaw(v.Sum64() ^ salt). This
+// lets one filter hold several namespaces, whose items do not collide more
+// than any two different items do
+//
+// Items must be tested with ContainsSalted and the salt they were added
+// with; salts should be well-distributed, say hashes of the namespace names
+func (f *Filter) AddSalted(v hash.Hash64, salt uint64) {
+	f.AddRaw(v.Sum64() ^ salt)
+}
+
+// ContainsSalted tests if f contains v within the namespace salt, see
+// AddSalted
+// false: f definitely does not contain value v with salt
+// true:  f maybe contains value v with salt
+func (f *Filter) ContainsSalted(v hash.Hash64, salt uint64) bool {
+	return f.ContainsRaw(v.Sum64() ^ salt)
+}
+
+// ContainsDetailed tests if f contains v like Contains, also reporting how
+// many of its totalPositions, K(), bit positions are set, for diagnosing
+// hash distribution problems; any fewer than all means v is definitely not
+// in f
+func (f *Filter) ContainsDetailed(v hash.Hash64) (ok bool, matchedPositions, totalPositions int) {
 	h := f.hash(v)
-	f.lock.RLock()
-	defer f.lock.RUnlock()
-	r := uint64(1)
+	f.rlock()
+	defer f.runlock()
 	for _, i := range h {
-		// r |= f.getBit(k)
 		i %= f.m
+		matchedPositions += int((f.bits[i>>6].Load() >> uint(i&0x3f)) & 1)
+	}
+	return matchedPositions == len(h) && len(h) > 0, matchedPositions, len(h)
+}
+
+// ContainsApprox tests if at most maxMisses of v's K() bit positions are
+// unset, so that v is still found after some of its bits are lost; with
+// maxMisses 0 it is Contains
+//
+// Each tolerated miss raises the false positive probability well above
+// FalsePositiveProbability: with q the fraction of bits set, an absent
+// value now passes with the odds of at least K()-maxMisses of K() positions
+// being set, roughly C(K(), maxMisses) * q^(K()-maxMisses) for small q
+func (f *Filter) ContainsApprox(v hash.Hash64, maxMisses int) bool {
+	_, matched, total := f.ContainsDetailed(v)
+	return total > 0 && total-matched <= maxMisses
+}
+
+// MembershipScore rates how likely f is to contain v, from 0 to 1, for
+// ranking many candidates rather than accepting or rejecting each
+//
+// It is the fraction j/K() of v's bit positions that are set, weighted by
+// 1 - q^j, how unlikely j set positions are by chance in a filter whose
+// fraction q of set bits is 1 - exp(-K()*N()/M()), going by N() rather than
+// counting bits: a full match scores near 1 in a sparse filter and near 0
+// in a saturated one, and any unset position lowers the score. It is a
+// heuristic for ordering, not a calibrated probability
+func (f *Filter) MembershipScore(v hash.Hash64) float64 {
+	_, matched, total := f.ContainsDetailed(v)
+	if matched == 0 {
+		return 0
+	}
+	k, n, m := float64(f.K()), float64(f.N()), float64(f.M())
+	q := -math.Expm1(-k * n / m)
+	return float64(matched) / float64(total) * (1 - math.Pow(q, float64(matched)))
+}
+
+// contains tests for rawHash; the caller must hold f.lock
+func (f *Filter) contains(rawHash uint64) bool {
+	if f.m == 0 {
+		return false
+	}
+	r := uint64(1)
+	if f.positions != nil {
+		for _, i := range f.positions(rawHash, len(f.keys)) {
+			i %= f.m
+			r &= (f.bits[i>>6].Load() >> uint(i&0x3f)) & uint64(1)
+		}
+		return uint64ToBool(r)
+	}
+	for _, key := range f.keys {
+		// r |= f.getBit(k)
+		i := (rawHash ^ key) % f.m
 		r &= (f.bits[i>>6].Load() >> uint(i&0x3f)) & uint64(1)
 	}
 	return uint64ToBool(r)
 }
 
+// ContainsAll tests if f contains every item in items, stopping at the
+// first item f definitely does not contain
+// false: f definitely does not contain at least one of items
+// true:  f maybe contains all of items, including when items is empty
+func (f *Filter) ContainsAll(items []hash.Hash64) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	for _, v := range items {
+		if !f.contains(v.Sum64()) {
+			return false
+		}
+	}
+	return true
+}
+
+// ContainsAny tests if f contains at least one item in items, stopping at
+// the first item f maybe contains
+// false: f definitely does not contain any of items, including when items
+// is empty
+// true:  f maybe contains at least one of items
+func (f *Filter) ContainsAny(items []hash.Hash64) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	for _, v := range items {
+		if f.contains(v.Sum64()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsBitmap tests if f contains each item in items, packing the
+// results into (len(items)+63)/64 words: bit i%64 of word i/64 is set iff f
+// maybe contains items[i]
+func (f *Filter) ContainsBitmap(items []hash.Hash64) []uint64 {
+	out := make([]uint64, (len(items)+63)/64)
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	for i, v := range items {
+		if f.contains(v.Sum64()) {
+			out[i>>6] |= 1 << uint(i&0x3f)
+		}
+	}
+	return out
+}
+
 // Copy f to a new Bloom filter
 func (f *Filter) Copy() (*Filter, error) {
 	out, err := f.NewCompatible()
@@ -124,29 +631,160 @@ func (f *Filter) Copy() (*Filter, error) {
 	return out, nil
 }
 
-// UnionInPlace merges Bloom filter f2 into f
+// Clear f back to an empty filter, keeping its size and keys so it remains
+// compatible with the filters it was compatible with before
+func (f *Filter) Clear() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.shared.Load() {
+		// the bits are a Snapshot's too, and fresh ones are already clear
+		f.bits = make([]atomic.Uint64, len(f.bits))
+		f.shared.Store(false)
+	} else {
+		for i := range f.bits {
+			// loads are cheaper than stores, so this keeps clearing sparse
+			// filters, such as for PutPooled, cheap
+			if f.bits[i].Load() != 0 {
+				f.bits[i].Store(0)
+			}
+		}
+	}
+	f.n.Store(0)
+}
+
+// UnionInPlace merges Bloom filter f2 into f, adding N() of f2 to that of
+// f, saturating at math.MaxUint64 rather than wrapping around
 func (f *Filter) UnionInPlace(f2 *Filter) error {
-	f.lock.RLock()
-	defer f.lock.RUnlock()
-	f2.lock.RLock()
-	defer f2.lock.RUnlock()
+	defer lockRWritePair(f, f2)()
 
 	if err := f.verifyCompatible(f2); err != nil {
 		return err
 	}
 
-	for i, word := range f2.bits {
-		f.bits[i].Or(word.Load())
+	for i := range f2.bits {
+		f.bits[i].Or(f2.bits[i].Load())
 	}
+	f.maskTail()
+	addN(&f.n, f2.n.Load())
 	return nil
 }
 
-// Union merges f2 and f2 into a new Filter out
+// DrainTo merges f into dst, adding N() of f to that of dst (saturating, see
+// UnionInPlace), and clears f,
+// all in one step, so that no element added to f concurrently is lost
+// between the two or ends up in both
+func (f *Filter) DrainTo(dst *Filter) error {
+	if f == dst {
+		return errDrainSelf()
+	}
+	lock := func() {
+		f.lock.Lock()
+		f.unshareLocked()
+	}
+	if lockedFirst(f, dst) {
+		lock()
+		dst.lockRWrite()
+	} else {
+		dst.lockRWrite()
+		lock()
+	}
+	defer f.lock.Unlock()
+	defer dst.lock.RUnlock()
+
+	if err := f.verifyCompatible(dst); err != nil {
+		return err
+	}
+
+	for i := range f.bits {
+		dst.bits[i].Or(f.bits[i].Swap(0))
+	}
+	addN(&dst.n, f.n.Swap(0))
+	return nil
+}
+
+// Union merges f and f2 into a new Filter out, with N() the sum of theirs,
+// saturating at math.MaxUint64 rather than wrapping around
 func (f *Filter) Union(f2 *Filter) (out *Filter, err error) {
-	f.lock.RLock()
-	defer f.lock.RUnlock()
-	f2.lock.RLock()
-	defer f2.lock.RUnlock()
+	return f.union(f2, false)
+}
+
+// UnionStrict merges f and f2 into a new Filter out like Union, but fails
+// when the sum of their N()s would overflow
+func (f *Filter) UnionStrict(f2 *Filter) (out *Filter, err error) {
+	return f.union(f2, true)
+}
+
+func (f *Filter) union(f2 *Filter, strict bool) (out *Filter, err error) {
+	defer rlockPair(f, f2)()
+	if err := f.verifyCompatible(f2); err != nil {
+		return nil, err
+	}
+	n, carry := bits.Add64(f.n.Load(), f2.n.Load(), 0)
+	if carry != 0 {
+		if strict {
+			return nil, errOverflowN()
+		}
+		n = math.MaxUint64
+	}
+	out, err = f.NewCompatible()
+	if err != nil {
+		return nil, err
+	}
+	for i := range f2.bits {
+		out.bits[i].Store(f.bits[i].Load() | f2.bits[i].Load())
+	}
+	out.n.Store(n)
+	return out, nil
+}
+
+// IntersectInPlace keeps only the bits of f that are also set in f2
+//
+// N() of the result is re-estimated from the remaining set bits and capped
+// at the smaller of the two N()s; it is only an estimate of the size of the
+// intersection, neither bound: colliding bits from either side inflate it,
+// while the density estimate itself can fall below the true size
+func (f *Filter) IntersectInPlace(f2 *Filter) error {
+	defer lockRWritePair(f, f2)()
+
+	if err := f.verifyCompatible(f2); err != nil {
+		return err
+	}
+
+	for i := range f2.bits {
+		f.bits[i].And(f2.bits[i].Load())
+	}
+	f.n.Store(min(f.estimateN(f.count()), f.n.Load(), f2.n.Load()))
+	return nil
+}
+
+// Intersect ANDs f and f2 into a new Filter out
+//
+// N() of out is an estimate, see IntersectInPlace
+func (f *Filter) Intersect(f2 *Filter) (out *Filter, err error) {
+	defer rlockPair(f, f2)()
+	if err := f.verifyCompatible(f2); err != nil {
+		return nil, err
+	}
+	out, err = f.NewCompatible()
+	if err != nil {
+		return nil, err
+	}
+	for i := range f2.bits {
+		out.bits[i].Store(f.bits[i].Load() & f2.bits[i].Load())
+	}
+	out.n.Store(min(out.estimateN(out.count()), f.n.Load(), f2.n.Load()))
+	return out, nil
+}
+
+// Difference of f and f2 into a new Filter out, with the bits set in f but
+// not in f2, approximating the items likely in f but not in f2
+//
+// The result is lossy: a bit belonging to an item only in f is cleared
+// whenever any item of f2 also sets it, so items only in f can go missing
+// from out, unlike with Union or Intersect. N() of out is re-estimated from
+// its set bits, capped at N() of f
+func (f *Filter) Difference(f2 *Filter) (out *Filter, err error) {
+	defer rlockPair(f, f2)()
 	if err := f.verifyCompatible(f2); err != nil {
 		return nil, err
 	}
@@ -154,8 +792,9 @@ func (f *Filter) Union(f2 *Filter) (out *Filter, err error) {
 	if err != nil {
 		return nil, err
 	}
-	for i, word := range f2.bits {
-		out.bits[i].Store(f.bits[i].Load() | word.Load())
+	for i := range f2.bits {
+		out.bits[i].Store(f.bits[i].Load() &^ f2.bits[i].Load())
 	}
+	out.n.Store(min(out.estimateN(out.count()), f.n.Load()))
 	return out, nil
 }
@@ -11,8 +11,18 @@
 package bloomfilter
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"math"
 	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // a read-only type that conforms to hash.Hash64, but only Sum64() works.
@@ -93,6 +103,780 @@ func Test0(t *testing.T) {
 	}
 }
 
+func TestAddNew(t *testing.T) {
+	f, _ := New(10000, 5)
+	if !f.AddNew(hashableUint64(1)) {
+		t.Error("the first AddNew should set new bits")
+	}
+	if f.AddNew(hashableUint64(1)) {
+		t.Error("the second AddNew should not set new bits")
+	}
+	if f.N() != 2 {
+		t.Errorf("expected n=2, got %d", f.N())
+	}
+	if !f.Contains(hashableUint64(1)) {
+		t.Error("definitely does not contain 1, but it should")
+	}
+
+	g, _ := NewWithPositionFunc(10000, 5, kirschMitzenmacher)
+	if !g.AddNew(hashableUint64(1)) || g.AddNew(hashableUint64(1)) {
+		t.Error("AddNew should honour the position func")
+	}
+}
+
+func TestTestAndAdd(t *testing.T) {
+	f, _ := New(10000, 5)
+	if !f.TestAndAdd(hashableUint64(1)) {
+		t.Error("the first TestAndAdd should report the item as new")
+	}
+	if f.TestAndAdd(hashableUint64(1)) {
+		t.Error("the second TestAndAdd should report the item as present")
+	}
+	if !f.Contains(hashableUint64(1)) || f.N() != 2 {
+		t.Errorf("expected the item to be contained with n=2, got n=%d", f.N())
+	}
+}
+
+func TestTestAndAddConcurrent(t *testing.T) {
+	f, _ := New(10000, 5)
+	for item := 0; item < 100; item++ {
+		expected := int32(1)
+		if f.Contains(hashableUint64(item)) {
+			// a false positive, so no one adds it
+			expected = 0
+		}
+		var wg sync.WaitGroup
+		var winners atomic.Int32
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if f.TestAndAdd(hashableUint64(item)) {
+					winners.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if winners.Load() != expected {
+			t.Errorf("item %d: expected %d TestAndAdd(s) to report it new, got %d", item, expected, winners.Load())
+		}
+		if !f.Contains(hashableUint64(item)) {
+			t.Errorf("item %d should be contained", item)
+		}
+	}
+}
+
+func TestContainsThenAdd(t *testing.T) {
+	f, _ := New(10000, 5)
+	if f.ContainsThenAdd(hashableUint64(1)) {
+		t.Error("the first ContainsThenAdd should report the item as absent")
+	}
+	if !f.ContainsThenAdd(hashableUint64(1)) {
+		t.Error("the second ContainsThenAdd should report the item as present")
+	}
+	if !f.Contains(hashableUint64(1)) || f.N() != 2 {
+		t.Errorf("expected the item to be contained with n=2, got n=%d", f.N())
+	}
+
+	snap := f.Snapshot()
+	before := snap.BitSet()
+	f.ContainsThenAdd(hashableUint64(2))
+	if !slices.Equal(snap.BitSet(), before) || snap.N() != 2 {
+		t.Error("ContainsThenAdd should not write to a snapshot's bits")
+	}
+}
+
+func TestDrainTo(t *testing.T) {
+	f, _ := New(10000, 5)
+	dst, _ := f.NewCompatible()
+	f.Add(hashableUint64(1))
+	dst.Add(hashableUint64(2))
+
+	if err := f.DrainTo(dst); err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsEmpty() || f.N() != 0 {
+		t.Errorf("expected f to be cleared, got n=%d", f.N())
+	}
+	if !dst.Contains(hashableUint64(1)) || !dst.Contains(hashableUint64(2)) || dst.N() != 2 {
+		t.Errorf("expected dst to contain both items with n=2, got n=%d", dst.N())
+	}
+
+	if err := f.DrainTo(f); err == nil {
+		t.Error("expected an error draining into itself")
+	}
+	incompatible, _ := New(10000, 5)
+	if err := f.DrainTo(incompatible); err == nil {
+		t.Error("expected an error draining into an incompatible filter")
+	}
+}
+
+func TestDrainToConcurrentAdd(t *testing.T) {
+	const adders, perAdder = 4, 2000
+	f, _ := New(100000, 5)
+	dst, _ := f.NewCompatible()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for g := 0; g < adders; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perAdder; i++ {
+				f.Add(hashableUint64(g*perAdder + i))
+			}
+		}(g)
+	}
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				if err := f.DrainTo(dst); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	<-drained
+	if err := f.DrainTo(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if dst.N() != adders*perAdder {
+		t.Errorf("expected n=%d, got %d", adders*perAdder, dst.N())
+	}
+	for i := 0; i < adders*perAdder; i++ {
+		if !dst.Contains(hashableUint64(i)) {
+			t.Fatalf("dst lost item %d", i)
+		}
+	}
+}
+
+func TestDrainToBothWays(t *testing.T) {
+	const rounds = 20000
+	a, _ := New(10000, 5)
+	b, _ := a.NewCompatible()
+	for i := 0; i < 100; i++ {
+		a.Add(hashableUint64(i))
+	}
+
+	var wg sync.WaitGroup
+	for _, pair := range [][2]*Filter{{a, b}, {b, a}} {
+		wg.Add(1)
+		go func(src, dst *Filter) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if err := src.DrainTo(dst); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(pair[0], pair[1])
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("DrainTo in both directions deadlocked")
+	}
+
+	if a.N()+b.N() != 100 {
+		t.Errorf("expected n=100 between the two, got %d", a.N()+b.N())
+	}
+	for i := 0; i < 100; i++ {
+		if !a.Contains(hashableUint64(i)) && !b.Contains(hashableUint64(i)) {
+			t.Fatalf("item %d lost", i)
+		}
+	}
+}
+
+func TestContainsDetailed(t *testing.T) {
+	f, _ := NewWithSeed(1000, 5, 1)
+	f.Add(hashableUint64(1))
+
+	ok, matched, total := f.ContainsDetailed(hashableUint64(1))
+	if !ok || matched != 5 || total != 5 {
+		t.Errorf("expected a present item to match (true, 5, 5), got (%t, %d, %d)", ok, matched, total)
+	}
+
+	// an absent item sharing some, but not all, positions with item 1
+	absent := hashableUint64(1 ^ f.keys[0] ^ f.keys[1])
+	ok, matched, total = f.ContainsDetailed(absent)
+	if ok || matched >= 5 || matched < 2 || total != 5 {
+		t.Errorf("expected an absent item to match (false, 2-4, 5), got (%t, %d, %d)", ok, matched, total)
+	}
+	if f.Contains(absent) {
+		t.Error("ContainsDetailed should agree with Contains")
+	}
+}
+
+func TestContainsApprox(t *testing.T) {
+	f, _ := NewWithSeed(1000, 5, 1)
+	f.Add(hashableUint64(1))
+	if !f.ContainsApprox(hashableUint64(1), 0) {
+		t.Error("ContainsApprox with maxMisses 0 should find an added item")
+	}
+
+	// corrupt one of item 1's positions
+	i := (1 ^ f.keys[0]) % f.m
+	f.bits[i>>6].And(^(uint64(1) << (i & 0x3f)))
+
+	if f.Contains(hashableUint64(1)) || f.ContainsApprox(hashableUint64(1), 0) {
+		t.Error("expected a miss with one position cleared and maxMisses 0")
+	}
+	if !f.ContainsApprox(hashableUint64(1), 1) {
+		t.Error("expected a match with one position cleared and maxMisses 1")
+	}
+	if f.ContainsApprox(hashableUint64(2), 1) {
+		t.Error("expected an absent item to miss with maxMisses 1")
+	}
+}
+
+func TestMembershipScore(t *testing.T) {
+	f, _ := NewWithSeed(1000, 5, 1)
+	for i := 0; i < 50; i++ {
+		f.Add(hashableUint64(i))
+	}
+
+	present := f.MembershipScore(hashableUint64(1))
+	if present <= 0.5 || present > 1 {
+		t.Errorf("expected a present item to score in (0.5, 1], got %f", present)
+	}
+	for i := 1000; i < 1100; i++ {
+		v := hashableUint64(i)
+		if f.Contains(v) {
+			continue
+		}
+		if score := f.MembershipScore(v); score < 0 || score >= present {
+			t.Errorf("expected absent item %d to score in [0, %f), got %f", i, present, score)
+		}
+	}
+
+	sparse, _ := NewWithSeed(1000, 5, 1)
+	sparse.Add(hashableUint64(1))
+	if sparse.MembershipScore(hashableUint64(1)) <= present {
+		t.Error("a full match should score higher in a sparser filter")
+	}
+	if empty, _ := New(1000, 5); empty.MembershipScore(hashableUint64(1)) != 0 {
+		t.Error("nothing should score above 0 in an empty filter")
+	}
+}
+
+func TestUnionN(t *testing.T) {
+	f, _ := New(10000, 5)
+	f2, _ := f.NewCompatible()
+	f.Add(hashableUint64(1))
+	f2.Add(hashableUint64(2))
+	f2.Add(hashableUint64(3))
+
+	out, err := f.Union(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.N() != 3 {
+		t.Errorf("expected Union N()=3, got %d", out.N())
+	}
+	if err := f.UnionInPlace(f2); err != nil {
+		t.Fatal(err)
+	}
+	if f.N() != 3 || !f.Equal(out) {
+		t.Errorf("expected UnionInPlace to match Union with N()=3, got %d", f.N())
+	}
+}
+
+func TestUnionOverflow(t *testing.T) {
+	f, _ := New(10000, 5)
+	f2, _ := f.NewCompatible()
+	f.n.Store(math.MaxUint64 - 1)
+	f2.n.Store(2)
+
+	out, err := f.Union(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.N() != math.MaxUint64 {
+		t.Errorf("expected Union to saturate N() at %d, got %d", uint64(math.MaxUint64), out.N())
+	}
+	if _, err := f.UnionStrict(f2); err == nil {
+		t.Error("expected UnionStrict to fail on overflow")
+	}
+	if err := f.UnionInPlace(f2); err != nil {
+		t.Fatal(err)
+	}
+	if f.N() != math.MaxUint64 {
+		t.Errorf("expected UnionInPlace to saturate N() at %d, got %d", uint64(math.MaxUint64), f.N())
+	}
+
+	f.n.Store(math.MaxUint64 - 2)
+	out, err = f.UnionStrict(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.N() != math.MaxUint64 {
+		t.Errorf("expected UnionStrict N()=%d, got %d", uint64(math.MaxUint64), out.N())
+	}
+}
+
+func TestMaskTail(t *testing.T) {
+	clean, _ := New(100, 5)
+	clean.Add(hashableUint64(1))
+	cleanData, _ := clean.MarshalBinary()
+
+	bits := clean.BitSet()
+	bits[1] |= 1 << 63
+	imported, err := NewFromBitSet(100, clean.Keys(), bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported.n.Store(clean.N())
+	if !imported.Equal(clean) {
+		t.Error("stray bits past m should not affect Equal")
+	}
+	if data, _ := imported.MarshalBinary(); !bytes.Equal(data, cleanData) {
+		t.Error("stray bits past m should not affect MarshalBinary")
+	}
+
+	// marshal stray bits behind the back of maskTail
+	stray, _ := clean.Copy()
+	stray.bits[1].Or(1 << 63)
+	strayData, _ := stray.MarshalBinary()
+	unmarshalled := new(Filter)
+	if err := unmarshalled.UnmarshalBinary(strayData); err != nil {
+		t.Fatal(err)
+	}
+	if !unmarshalled.Equal(clean) {
+		t.Error("UnmarshalBinary should clear stray bits past m")
+	}
+	if err := clean.UnionInPlace(stray); err != nil {
+		t.Fatal(err)
+	}
+	if clean.BitSet()[1]>>(100-64) != 0 {
+		t.Error("UnionInPlace should clear stray bits past m")
+	}
+}
+
+func TestContainsBitmap(t *testing.T) {
+	f, _ := New(1000, 5)
+	items := make([]hash.Hash64, 150)
+	for i := range items {
+		items[i] = hashableUint64(i)
+		if i%3 == 0 {
+			f.Add(items[i])
+		}
+	}
+
+	bitmap := f.ContainsBitmap(items)
+	if len(bitmap) != 3 {
+		t.Fatalf("expected 3 words for 150 items, got %d", len(bitmap))
+	}
+	for i, v := range items {
+		if got := bitmap[i/64]>>(i%64)&1 == 1; got != f.Contains(v) {
+			t.Errorf("item %d: expected %t, got %t", i, f.Contains(v), got)
+		}
+	}
+	if bitmap[2]>>(150-128) != 0 {
+		t.Error("bits past the last item should be clear")
+	}
+	if len(f.ContainsBitmap(nil)) != 0 {
+		t.Error("expected no words for no items")
+	}
+}
+
+func TestSetBits(t *testing.T) {
+	f, _ := New(1000, 5)
+	expected := map[uint64]bool{}
+	for _, v := range []uint64{1, 2, 3, 1000} {
+		f.Add(hashableUint64(v))
+		for _, key := range f.keys {
+			expected[(v^key)%f.m] = true
+		}
+	}
+
+	var got []uint64
+	for i := range f.SetBits {
+		got = append(got, i)
+	}
+	if len(got) != len(expected) || uint64(len(got)) != f.Count() {
+		t.Errorf("expected %d set bits, got %d", len(expected), len(got))
+	}
+	for j, i := range got {
+		if !expected[i] {
+			t.Errorf("bit %d should not be set", i)
+		}
+		if j > 0 && got[j-1] >= i {
+			t.Errorf("bits should be in increasing order, got %d then %d", got[j-1], i)
+		}
+	}
+
+	count := 0
+	for range f.SetBits {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Errorf("expected to stop after 3 bits, got %d", count)
+	}
+}
+
+func TestZeroFilter(t *testing.T) {
+	var f Filter
+	f.Add(hashableUint64(1))
+	f.AddRaw(1)
+	if f.AddC(hashableUint64(1)) {
+		t.Error("AddC on a zero Filter should not report the item maybe present")
+	}
+	f.AddNew(hashableUint64(1))
+	f.TestAndAdd(hashableUint64(1))
+	if f.Contains(hashableUint64(1)) || f.ContainsRaw(1) {
+		t.Error("a zero Filter should contain nothing")
+	}
+	if ok, _, _ := f.ContainsDetailed(hashableUint64(1)); ok {
+		t.Error("a zero Filter should contain nothing")
+	}
+	if f.ContainsThenAdd(hashableUint64(1)) {
+		t.Error("a zero Filter should contain nothing")
+	}
+	if (&Filter{}).Freeze().ContainsRaw(1) {
+		t.Error("a frozen zero Filter should contain nothing")
+	}
+}
+
+// FuzzAddContains builds filters of arbitrary keys and small sizes, and
+// unmarshals arbitrary bytes, none of which may panic
+func FuzzAddContains(f *testing.F) {
+	f.Add([]byte{}, uint16(0), uint64(0))
+	f.Add([]byte{1, 2, 3, 4, 5, 6, 7, 8}, uint16(1), uint64(1))
+	f.Add(make([]byte, 16), uint16(2), uint64(2))
+	f.Add([]byte("0123456789abcdef01234567"), uint16(100), uint64(3))
+	seed, _ := New(100, 3)
+	data, _ := seed.MarshalBinary()
+	f.Add(data, uint16(64), uint64(4))
+	f.Add(make([]byte, len(data)), uint16(64), uint64(5))
+
+	f.Fuzz(func(t *testing.T, data []byte, m uint16, item uint64) {
+		keys := make([]uint64, len(data)/8)
+		for i := range keys {
+			keys[i] = binary.LittleEndian.Uint64(data[i*8:])
+		}
+		for _, newFilter := range []func() (*Filter, error){
+			func() (*Filter, error) { return NewWithKeys(uint64(m), keys) },
+			func() (*Filter, error) { return New(uint64(m), uint64(len(keys))) },
+			func() (*Filter, error) { return NewUnchecked(uint64(m), uint64(len(keys))) },
+		} {
+			bf, err := newFilter()
+			if err != nil {
+				if bf != nil {
+					t.Error("a filter should not be returned with an error")
+				}
+				continue
+			}
+			bf.AddRaw(item)
+			if !bf.ContainsRaw(item) {
+				t.Errorf("m=%d: definitely does not contain %d, but it should", m, item)
+			}
+		}
+
+		var bf Filter
+		if bf.UnmarshalBinary(data) == nil {
+			bf.AddRaw(item)
+			if !bf.ContainsRaw(item) {
+				t.Errorf("unmarshalled: definitely does not contain %d, but it should", item)
+			}
+		}
+	})
+}
+
+func TestAddSalted(t *testing.T) {
+	f, _ := New(10000, 5)
+	saltA, saltB := fnv64a("namespace a"), fnv64a("namespace b")
+	for i := 0; i < 100; i++ {
+		f.AddSalted(hashableUint64(i), saltA)
+	}
+	for i := 0; i < 100; i++ {
+		if !f.ContainsSalted(hashableUint64(i), saltA) {
+			t.Errorf("definitely does not contain %d with salt A, but it should", i)
+		}
+		if f.ContainsSalted(hashableUint64(i), saltB) {
+			t.Errorf("contains %d with salt B, but almost surely should not", i)
+		}
+	}
+}
+
+func TestAddCConcurrentCopy(t *testing.T) {
+	bf, _ := New(10000, 5)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				bf.AddC(hashableUint64(g*1000 + i))
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if _, err := bf.Copy(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for g := 0; g < 8; g++ {
+		for i := 0; i < 1000; i++ {
+			if !bf.Contains(hashableUint64(g*1000 + i)) {
+				t.Fatal("definitely does not contain ", g*1000+i, ", but it should")
+			}
+		}
+	}
+	if bf.N() != 8*1000 {
+		t.Errorf("expected N()=%d, got %d", 8*1000, bf.N())
+	}
+}
+
+func TestAddAll(t *testing.T) {
+	bf, _ := New(10000, 5)
+	items := make([]hash.Hash64, 0)
+	for _, x := range hashableUint64Values() {
+		items = append(items, x)
+	}
+	bf.AddAll(items)
+
+	for _, x := range hashableUint64Values() {
+		if !bf.Contains(x) {
+			t.Error("definitely does not contain ", x, ", but it should")
+		}
+	}
+	if bf.N() != uint64(len(items)) {
+		t.Errorf("expected N()=%d, got %d", len(items), bf.N())
+	}
+}
+
+// cancellingHashable cancels a context when it is hashed
+type cancellingHashable struct {
+	hashableUint64
+	cancel context.CancelFunc
+}
+
+func (h cancellingHashable) Sum64() uint64 {
+	h.cancel()
+	return h.hashableUint64.Sum64()
+}
+
+func TestAddAllContext(t *testing.T) {
+	bf, _ := New(100000, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	items := make([]hash.Hash64, 3*addAllContextChunk+10)
+	for i := range items {
+		items[i] = hashableUint64(i)
+	}
+	items[addAllContextChunk+5] = cancellingHashable{hashableUint64(addAllContextChunk + 5), cancel}
+
+	added, err := bf.AddAllContext(ctx, items)
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if added != 2*addAllContextChunk {
+		t.Errorf("expected %d added, got %d", 2*addAllContextChunk, added)
+	}
+	if bf.N() != uint64(added) {
+		t.Errorf("expected N()=%d, got %d", added, bf.N())
+	}
+	if !bf.ContainsAll(items[:added]) {
+		t.Error("the added prefix of items should all be present")
+	}
+
+	added, err = bf.AddAllContext(context.Background(), items)
+	if err != nil || added != len(items) {
+		t.Errorf("expected all %d added, got %d (err=%v)", len(items), added, err)
+	}
+}
+
+func TestContainsAllAny(t *testing.T) {
+	bf, _ := New(10000, 5)
+	var in, out []hash.Hash64
+	for _, x := range hashableUint64Values() {
+		bf.Add(x)
+		in = append(in, x)
+	}
+	for _, x := range hashableUint64NotValues() {
+		if !bf.Contains(x) {
+			out = append(out, x)
+		}
+	}
+
+	if !bf.ContainsAll(in) {
+		t.Error("ContainsAll of added items should be true")
+	}
+	if bf.ContainsAll(append(in, out...)) {
+		t.Error("ContainsAll with absent items should be false")
+	}
+	if !bf.ContainsAny(append(out, in[0])) {
+		t.Error("ContainsAny with one added item should be true")
+	}
+	if bf.ContainsAny(out) {
+		t.Error("ContainsAny of absent items should be false")
+	}
+
+	if !bf.ContainsAll(nil) {
+		t.Error("ContainsAll of no items should be true")
+	}
+	if bf.ContainsAny(nil) {
+		t.Error("ContainsAny of no items should be false")
+	}
+}
+
+func TestUnsafe(t *testing.T) {
+	bf, _ := NewUnsafe(10000, 5)
+	for _, x := range hashableUint64Values() {
+		bf.Add(x)
+	}
+	for _, x := range hashableUint64Values() {
+		if !bf.Contains(x) || !bf.AddC(x) {
+			t.Error("definitely does not contain ", x, ", but it should")
+		}
+	}
+	if f2, _ := bf.Copy(); !f2.Equal(bf) || f2.unlocked {
+		t.Error("Copy() of an unsafe filter should be equal and safe")
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	f, _ := New(10000, 5)
+	f2, _ := f.NewCompatible()
+
+	for i := 0; i < 200; i++ {
+		f.Add(hashableUint64(i))
+	}
+	for i := 100; i < 300; i++ {
+		f2.Add(hashableUint64(i))
+	}
+
+	out, err := f.Intersect(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 100; i < 200; i++ {
+		if !out.Contains(hashableUint64(i)) {
+			t.Fatal("intersection definitely does not contain ", i,
+				", but it should")
+		}
+	}
+	if out.N() == 0 || out.N() > 200 {
+		t.Errorf("expected 0 < N() <= 200, got %d", out.N())
+	}
+
+	err = f.IntersectInPlace(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.N() != out.N() {
+		t.Errorf("expected N()=%d, got %d", out.N(), f.N())
+	}
+	for i := range f.bits {
+		if f.bits[i].Load() != out.bits[i].Load() {
+			t.Fatal("IntersectInPlace and Intersect differ at word ", i)
+		}
+	}
+}
+
+func TestIntersectIncompatible(t *testing.T) {
+	f, _ := New(10000, 5)
+	f2, _ := New(10000, 5)
+
+	if _, err := f.Intersect(f2); err == nil {
+		t.Error("expected an error intersecting incompatible filters")
+	}
+	if err := f.IntersectInPlace(f2); err == nil {
+		t.Error("expected an error intersecting incompatible filters")
+	}
+}
+
+func TestClear(t *testing.T) {
+	bf, _ := New(10000, 5)
+	f2, _ := bf.NewCompatible()
+	for _, x := range hashableUint64Values() {
+		bf.Add(x)
+	}
+
+	bf.Clear()
+
+	for _, x := range hashableUint64Values() {
+		if bf.Contains(x) {
+			t.Error("cleared filter may contain ", x, ", but it should not")
+		}
+	}
+	if bf.N() != 0 {
+		t.Errorf("expected N()=0, got %d", bf.N())
+	}
+	if !bf.IsCompatible(f2) {
+		t.Error("cleared filter is no longer compatible")
+	}
+}
+
+func TestDifference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	f, _ := New(100000, 5)
+	f2, _ := f.NewCompatible()
+
+	var common, extra []hashableUint64
+	for i := 0; i < 100; i++ {
+		v := hashableUint64(rng.Uint64())
+		common = append(common, v)
+		f.Add(v)
+		f2.Add(v)
+	}
+	for i := 0; i < 100; i++ {
+		v := hashableUint64(rng.Uint64())
+		extra = append(extra, v)
+		f.Add(v)
+	}
+
+	out, err := f.Difference(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// items in both are definitely gone, while in a sparse filter almost all
+	// of the extra items survive
+	for _, v := range common {
+		if out.Contains(v) {
+			t.Error("difference may contain common item ", v)
+		}
+	}
+	found := 0
+	for _, v := range extra {
+		if out.Contains(v) {
+			found++
+		}
+	}
+	if found < 90 {
+		t.Errorf("expected most of the 100 extra items in the difference, got %d", found)
+	}
+	if out.N() > f.N() {
+		t.Errorf("expected N() <= %d, got %d", f.N(), out.N())
+	}
+}
+
 func BenchmarkAddX10kX5(b *testing.B) {
 	b.StopTimer()
 	bf, _ := New(10000, 5)
@@ -102,6 +886,54 @@ func BenchmarkAddX10kX5(b *testing.B) {
 	}
 }
 
+func randomHashables(n int) []hash.Hash64 {
+	items := make([]hash.Hash64, n)
+	for i := range items {
+		items[i] = hashableUint64(rand.Uint32())
+	}
+	return items
+}
+
+func BenchmarkAddLoop1kX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	items := randomHashables(1000)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range items {
+			bf.Add(v)
+		}
+	}
+}
+
+func BenchmarkAddAll1kX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	items := randomHashables(1000)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		bf.AddAll(items)
+	}
+}
+
+func BenchmarkAddUnsafeX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := NewUnsafe(10000, 5)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Add(hashableUint64(i))
+	}
+}
+
+func BenchmarkAddSafeX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Add(hashableUint64(i))
+	}
+}
+
 func BenchmarkContains1kX10kX5(b *testing.B) {
 	b.StopTimer()
 	bf, _ := New(10000, 5)
@@ -125,3 +957,92 @@ func BenchmarkContains100kX10BX20(b *testing.B) {
 		bf.Contains(hashableUint64(rand.Uint32()))
 	}
 }
+
+func TestSingleWord(t *testing.T) {
+	for _, m := range []uint64{MMin, 3, 7, 63, 64} {
+		f, err := New(m, 3)
+		if err != nil {
+			t.Fatalf("m=%d: %v", m, err)
+		}
+		f2, _ := f.NewCompatible()
+		if len(f.BitSet()) != 1 {
+			t.Fatalf("m=%d: expected 1 word, got %d", m, len(f.BitSet()))
+		}
+		f.Add(hashableUint64(1))
+		f2.Add(hashableUint64(2))
+		if !f.Contains(hashableUint64(1)) || !f2.Contains(hashableUint64(2)) {
+			t.Errorf("m=%d: added items should be found", m)
+		}
+		if f.BitSet()[0]>>(m-1)>>1 != 0 {
+			t.Errorf("m=%d: bits past m are set: %#x", m, f.BitSet()[0])
+		}
+
+		union, err := f.Union(f2)
+		if err != nil {
+			t.Fatalf("m=%d: %v", m, err)
+		}
+		if union.BitSet()[0] != f.BitSet()[0]|f2.BitSet()[0] || union.N() != 2 {
+			t.Errorf("m=%d: Union should OR the word and sum N()", m)
+		}
+		if err := f2.UnionInPlace(f); err != nil {
+			t.Fatalf("m=%d: %v", m, err)
+		}
+		if !f2.Equal(union) {
+			t.Errorf("m=%d: UnionInPlace should match Union", m)
+		}
+
+		c, err := union.Copy()
+		if err != nil {
+			t.Fatalf("m=%d: %v", m, err)
+		}
+		if !c.Equal(union) {
+			t.Errorf("m=%d: Copy should equal its source", m)
+		}
+
+		data, err := union.MarshalBinary()
+		if err != nil {
+			t.Fatalf("m=%d: %v", m, err)
+		}
+		if uint64(len(data)) != binarySize(3, m) {
+			t.Errorf("m=%d: expected %d byte(s), got %d", m, binarySize(3, m), len(data))
+		}
+		out := new(Filter)
+		if err := out.UnmarshalBinary(data); err != nil {
+			t.Fatalf("m=%d: %v", m, err)
+		}
+		if !out.Equal(union) || out.N() != 2 {
+			t.Errorf("m=%d: binary round trip should preserve the filter", m)
+		}
+
+		js, err := union.MarshalJSON()
+		if err != nil {
+			t.Fatalf("m=%d: %v", m, err)
+		}
+		out = new(Filter)
+		if err := out.UnmarshalJSON(js); err != nil {
+			t.Fatalf("m=%d: %v", m, err)
+		}
+		if !out.Equal(union) {
+			t.Errorf("m=%d: JSON round trip should preserve the filter", m)
+		}
+
+		// same keys, and one word each, but a different m
+		other, _ := NewWithKeys(m%64+2, f.Keys())
+		if _, err := f.Union(other); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("m=%d: expected ErrIncompatible against m=%d, got %v", m, other.M(), err)
+		}
+		if err := other.UnionInPlace(f); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("m=%d: expected ErrIncompatible against m=%d, got %v", m, other.M(), err)
+		}
+	}
+
+	// below MMin, only by NewUnchecked
+	f, err := NewUnchecked(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Add(hashableUint64(1))
+	if !f.Contains(hashableUint64(2)) || f.BitSet()[0] != 1 {
+		t.Errorf("m=1: every item should map to the one bit, got %#x", f.BitSet()[0])
+	}
+}
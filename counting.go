@@ -0,0 +1,162 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"hash"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// counterBits is the width of each CountingFilter counter
+	counterBits = 4
+	// counterMax is the value at which a counter saturates
+	counterMax = 1<<counterBits - 1
+	// countersPerWord is how many counters are packed into a uint64
+	countersPerWord = 64 / counterBits
+)
+
+// CountingFilter is an opaque counting Bloom filter type, which unlike
+// Filter supports Remove()
+//
+// Each of its m slots is a 4-bit counter rather than a bit. A counter that
+// reaches 15 saturates and is never decremented again, as its true count is
+// no longer known; the items behind it can then no longer be fully removed,
+// but no other item is ever corrupted by it
+type CountingFilter struct {
+	lock     sync.RWMutex    // same semantics as Filter.lock
+	counters []atomic.Uint64 // mutable
+	keys     []uint64        // immutable after init
+	m        uint64          // number of counters; immutable after init
+	n        atomic.Uint64   // number of inserted elements; mutable
+}
+
+// NewCounting CountingFilter with CSPRNG keys
+//
+// m is the number of counters, >= 2 and <= MMax, taking m/2 bytes
+//
+// k is the number of random keys, >= 1
+func NewCounting(m, k uint64) (*CountingFilter, error) {
+	if err := checkM(m); err != nil {
+		return nil, err
+	}
+	keys, err := newUniqueRandKeys(k)
+	if err != nil {
+		return nil, err
+	}
+	return &CountingFilter{
+		counters: make([]atomic.Uint64, (m+countersPerWord-1)/countersPerWord),
+		keys:     keys,
+		m:        m,
+	}, nil
+}
+
+// NewCountingOptimal CountingFilter with random CSPRNG keys, sized like
+// NewOptimal
+func NewCountingOptimal(maxN uint64, p float64) (*CountingFilter, error) {
+	if !(p > 0 && p < 1) {
+		return nil, errP()
+	}
+	m := OptimalM(maxN, p)
+	return NewCounting(m, OptimalK(m, maxN))
+}
+
+// M is the number of counters
+func (c *CountingFilter) M() uint64 {
+	return c.m
+}
+
+// K is the count of keys
+func (c *CountingFilter) K() uint64 {
+	return uint64(len(c.keys))
+}
+
+// N is how many elements are present
+// (actually, how many Add()s less Remove()s have been performed?)
+func (c *CountingFilter) N() uint64 {
+	return c.n.Load()
+}
+
+// update applies step to the counter of slot i, with a CAS loop so that
+// concurrent updates of neighbouring counters in the same word are not lost;
+// step returns the new value of a counter, or false to leave it unchanged
+func (c *CountingFilter) update(i uint64, step func(counter uint64) (uint64, bool)) {
+	word := &c.counters[i/countersPerWord]
+	shift := (i % countersPerWord) * counterBits
+	for {
+		old := word.Load()
+		counter, ok := step((old >> shift) & counterMax)
+		if !ok {
+			return
+		}
+		updated := old&^(counterMax<<shift) | counter<<shift
+		if word.CompareAndSwap(old, updated) {
+			return
+		}
+	}
+}
+
+func incrementCounter(counter uint64) (uint64, bool) {
+	return counter + 1, counter < counterMax
+}
+
+// decrementCounter never goes below zero, nor touches a saturated counter
+func decrementCounter(counter uint64) (uint64, bool) {
+	return counter - 1, counter > 0 && counter < counterMax
+}
+
+// Add a hashable item, v, to the filter
+func (c *CountingFilter) Add(v hash.Hash64) {
+	rawHash := v.Sum64()
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, key := range c.keys {
+		c.update((rawHash^key)%c.m, incrementCounter)
+	}
+	c.n.Add(1)
+}
+
+// Remove a hashable item, v, from the filter
+//
+// v must have been added before; removing an item that was never added
+// decrements the counters of other items, which can make them falsely
+// report that they are definitely not present
+func (c *CountingFilter) Remove(v hash.Hash64) {
+	rawHash := v.Sum64()
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, key := range c.keys {
+		c.update((rawHash^key)%c.m, decrementCounter)
+	}
+	for {
+		n := c.n.Load()
+		if n == 0 || c.n.CompareAndSwap(n, n-1) {
+			return
+		}
+	}
+}
+
+// Contains tests if c contains v
+// false: c definitely does not contain value v
+// true:  c maybe contains value v
+func (c *CountingFilter) Contains(v hash.Hash64) bool {
+	rawHash := v.Sum64()
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, key := range c.keys {
+		i := (rawHash ^ key) % c.m
+		shift := (i % countersPerWord) * counterBits
+		if (c.counters[i/countersPerWord].Load()>>shift)&counterMax == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package bloomfilter
+
+import (
+	"testing"
+)
+
+func TestCountingFilterRemove(t *testing.T) {
+	c, err := NewCounting(10000, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range hashableUint64Values() {
+		c.Add(x)
+	}
+	for _, x := range hashableUint64Values() {
+		if !c.Contains(x) {
+			t.Error("definitely does not contain ", x, ", but it should")
+		}
+	}
+
+	removed := hashableUint64Values()[0]
+	c.Remove(removed)
+	if c.Contains(removed) {
+		t.Error("may contain removed ", removed, ", but it should not")
+	}
+	for _, x := range hashableUint64Values()[1:] {
+		if !c.Contains(x) {
+			t.Error("definitely does not contain ", x, " after removing another")
+		}
+	}
+	if c.N() != uint64(len(hashableUint64Values())-1) {
+		t.Errorf("expected N()=%d, got %d", len(hashableUint64Values())-1, c.N())
+	}
+}
+
+func TestCountingFilterSaturation(t *testing.T) {
+	c, _ := NewCounting(10000, 5)
+	v := hashableUint64(7)
+
+	// saturate v's counters, which then stick
+	for i := 0; i < counterMax+5; i++ {
+		c.Add(v)
+	}
+	for i := 0; i < counterMax+5; i++ {
+		c.Remove(v)
+	}
+	if !c.Contains(v) {
+		t.Error("saturated counters should never be decremented")
+	}
+
+	// counters never go below zero
+	c2, _ := NewCounting(10000, 5)
+	c2.Remove(v)
+	c2.Add(v)
+	if !c2.Contains(v) || c2.N() != 1 {
+		t.Error("removing from an empty filter should not underflow")
+	}
+}
+
+func TestNewCountingInvalid(t *testing.T) {
+	if _, err := NewCounting(1, 5); err == nil {
+		t.Error("expected an error for m < MMin")
+	}
+	if _, err := NewCounting(100, 0); err == nil {
+		t.Error("expected an error for k < KMin")
+	}
+	if _, err := NewCountingOptimal(100, 1); err == nil {
+		t.Error("expected an error for p >= 1")
+	}
+}
@@ -14,22 +14,113 @@ import (
 	"strings"
 )
 
-func errHash() error {
-	return fmt.Errorf(
+// errHash, errK, errM, errMMax and errUniqueKeys return the same error each time, so that callers
+// can tell them apart with errors.Is
+var (
+	errHashValue = fmt.Errorf(
 		"Hash mismatch, the Bloom filter is probably corrupt")
+	errKValue = fmt.Errorf(
+		"keys must have length %d or greater", KMin)
+	errMValue = fmt.Errorf(
+		"m (number of bits in the Bloom filter) must be >= %d", MMin)
+	errMMaxValue = fmt.Errorf(
+		"m (number of bits in the Bloom filter) must be <= %d", uint64(MMax))
+	errUniqueKeysValue = fmt.Errorf(
+		"Bloom filter keys must be unique")
+)
+
+func errHash() error {
+	return errHashValue
 }
 func errK() error {
-	return fmt.Errorf(
-		"keys must have length %d or greater", KMin)
+	return errKValue
 }
 func errM() error {
+	return errMValue
+}
+func errMMax() error {
+	return errMMaxValue
+}
+func errMZero() error {
 	return fmt.Errorf(
-		"m (number of bits in the Bloom filter) must be >= %d", MMin)
+		"m (number of bits in the Bloom filter) must be >= 1")
 }
-func errUniqueKeys() error {
+func errP() error {
 	return fmt.Errorf(
-		"Bloom filter keys must be unique")
+		"p (false positive probability) must be > 0 and < 1")
+}
+func errSize() error {
+	return fmt.Errorf(
+		"Bloom filter data is truncated or has trailing bytes")
+}
+func errVersion(v uint8) error {
+	return fmt.Errorf(
+		"unsupported Bloom filter binary format version %d"+
+			" (supported: %d, %d and the unversioned layout)",
+		v, binaryVersion, binaryVersionSeeded)
+}
+func errJSONBits(expected, actual int) error {
+	return fmt.Errorf(
+		"bits must be %d byte(s) for m, got %d", expected, actual)
+}
+func errJSONK(k uint64, keys int) error {
+	return fmt.Errorf(
+		"k=%d does not match the %d key(s) given", k, keys)
+}
+func errNoFilters() error {
+	return fmt.Errorf(
+		"at least one Bloom filter is required")
+}
+func errResize(m, newM uint64) error {
+	return fmt.Errorf(
+		"cannot resize a Bloom filter of M=%d to M=%d, which is not a multiple", m, newM)
+}
+func errDownsample(m, factor uint64) error {
+	return fmt.Errorf(
+		"cannot downsample a Bloom filter of M=%d by %d, which does not divide it"+
+			" into at least %d bits", m, factor, MMin)
+}
+func errBitSet(expected, actual int) error {
+	return fmt.Errorf(
+		"bits must be %d word(s) for m, got %d", expected, actual)
+}
+func errShards() error {
+	return fmt.Errorf(
+		"shards (number of shards in the Bloom filter) must be >= 1")
+}
+func errVarName(name string) error {
+	return fmt.Errorf(
+		"%q is not a valid Go identifier", name)
+}
+func errNotEmpty() error {
+	return fmt.Errorf(
+		"Bloom filter must be empty")
+}
+func errRekeySelf() error {
+	return fmt.Errorf(
+		"cannot rekey a Bloom filter from itself")
+}
+func errRekeyMissing(i int) error {
+	return fmt.Errorf(
+		"items[%d] is definitely not in the source Bloom filter", i)
+}
+func errDrainSelf() error {
+	return fmt.Errorf(
+		"cannot drain a Bloom filter into itself")
+}
+func errOverflowN() error {
+	return fmt.Errorf(
+		"n (number of inserted elements) of the union overflows uint64")
 }
+func errUniqueKeys() error {
+	return errUniqueKeysValue
+}
+
+// incompatibleHint is how to avoid errIncompatible, as there is no fixing it
+// after the fact
+const incompatibleHint = "bits cannot be rehashed without the original items, " +
+	"so build filters to be combined with the same M and keys, by NewCompatible, " +
+	"NewWithKeys with the same Keys(), or NewWithSeed with the same seed"
 
 type errIncompatible struct {
 	s []string
@@ -44,7 +135,8 @@ func (e *errIncompatible) Error() string {
 			out = append(out, i)
 		}
 	}
-	return fmt.Sprintf("Cannot perform union on two incompatible Bloom filters: %s", strings.Join(out, ", "))
+	return fmt.Sprintf("Cannot perform union on two incompatible Bloom filters: %s (%s)",
+		strings.Join(out, ", "), incompatibleHint)
 }
 
 func (e *errIncompatible) Is(err error) bool {
@@ -11,48 +11,43 @@ package bloomfilter
 
 import (
 	"compress/gzip"
+	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
-// ReadFrom r and overwrite f with new Bloom filter data
+// ReadFrom r and overwrite f with new Bloom filter data, streamed in the
+// binary format
+//
+// The existing bits of f are reused when the sizes match and no Snapshot
+// shares them, so on error the contents of f are unspecified
 func (f *Filter) ReadFrom(r io.Reader) (n int64, err error) {
-	f2, n, err := ReadFrom(r)
-	if err != nil {
-		return -1, err
-	}
 	f.lock.Lock()
 	defer f.lock.Unlock()
+
+	bits := f.bits
+	if f.shared.Load() {
+		// the bits are a Snapshot's too, so read into fresh ones
+		bits = nil
+	}
+	f2, n, err := readBinary(r, bits)
+	if err != nil {
+		return n, err
+	}
 	f.m = f2.m
-	f.n = f2.n
+	f.n.Store(f2.n.Load())
 	f.bits = f2.bits
+	f.shared.Store(false)
 	f.keys = f2.keys
+	f.seed, f.seeded = f2.seed, f2.seeded
+	f.pooled = false
 	return n, nil
 }
 
-// ReadFrom Reader r into a lossless-compressed Bloom filter f
+// ReadFrom Reader r into a new Bloom filter f, streamed in the binary format
 func ReadFrom(r io.Reader) (f *Filter, n int64, err error) {
-	rawR, err := gzip.NewReader(r)
-	if err != nil {
-		return nil, -1, err
-	}
-	defer func() {
-		err = rawR.Close()
-	}()
-
-	content, err := ioutil.ReadAll(rawR)
-	if err != nil {
-		return nil, -1, err
-	}
-
-	f = new(Filter)
-	n = int64(len(content))
-	err = f.UnmarshalBinary(content)
-	if err != nil {
-		return nil, -1, err
-	}
-	return f, n, nil
+	return readBinary(r, nil)
 }
 
 // ReadFile from filename into a lossless-compressed Bloom Filter f
@@ -63,30 +58,58 @@ func ReadFile(filename string) (f *Filter, n int64, err error) {
 		return nil, -1, err
 	}
 	defer func() {
-		err = r.Close()
+		if cerr := r.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	rawR, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer func() {
+		if cerr := rawR.Close(); err == nil {
+			err = cerr
+		}
 	}()
 
-	return ReadFrom(r)
+	return ReadFrom(rawR)
 }
 
-// WriteTo a Writer w from lossless-compressed Bloom Filter f
+// WriteTo a Writer w from Bloom Filter f, streamed in the binary format
 func (f *Filter) WriteTo(w io.Writer) (n int64, err error) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	rawW := gzip.NewWriter(w)
-	defer func() {
-		err = rawW.Close()
-	}()
+	n, _, err = f.writeBinary(w)
+	return n, err
+}
 
-	content, err := f.MarshalBinary()
+// WriteCompressedTo a Writer w from lossless-compressed Bloom Filter f, the
+// binary format wrapped in gzip, which shrinks sparse filters enormously
+func (f *Filter) WriteCompressedTo(w io.Writer) error {
+	rawW := gzip.NewWriter(w)
+	_, err := f.WriteTo(rawW)
 	if err != nil {
-		return -1, err
+		_ = rawW.Close()
+		return err
 	}
+	return rawW.Close()
+}
 
-	intN, err := rawW.Write(content)
-	n = int64(intN)
-	return n, err
+// ReadCompressedFrom r and overwrite f with lossless-compressed Bloom filter
+// data, as written by WriteCompressedTo
+func (f *Filter) ReadCompressedFrom(r io.Reader) error {
+	rawR, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	_, err = f.ReadFrom(rawR)
+	if err != nil {
+		_ = rawR.Close()
+		return err
+	}
+	return rawR.Close()
 }
 
 // WriteFile filename from a a lossless-compressed Bloom Filter f
@@ -97,8 +120,65 @@ func (f *Filter) WriteFile(filename string) (n int64, err error) {
 		return -1, err
 	}
 	defer func() {
-		err = w.Close()
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	rawW := gzip.NewWriter(w)
+	defer func() {
+		if cerr := rawW.Close(); err == nil {
+			err = cerr
+		}
 	}()
 
-	return f.WriteTo(w)
+	return f.WriteTo(rawW)
+}
+
+// WriteToFile path from Bloom Filter f, in the uncompressed binary format of
+// WriteTo, with mode 0644
+//
+// It writes to a temporary file beside path, syncs it to disk and renames it
+// over path, so path holds either the old or the new contents, never a
+// truncated file, even after a crash
+func (f *Filter) WriteToFile(path string) (err error) {
+	w, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = w.Close()
+			_ = os.Remove(w.Name())
+		}
+	}()
+
+	if _, err = f.WriteTo(w); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err = w.Chmod(0o644); err != nil {
+		return err
+	}
+	if err = w.Sync(); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return os.Rename(w.Name(), path)
+}
+
+// ReadFromFile path into a new Bloom filter f, as written by WriteToFile
+func ReadFromFile(path string) (f *Filter, err error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	f, _, err = ReadFrom(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
 }
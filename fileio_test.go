@@ -12,7 +12,13 @@ package bloomfilter
 
 import (
 	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestWriteRead(t *testing.T) {
@@ -38,3 +44,319 @@ func TestWriteRead(t *testing.T) {
 		t.Error("Filters not equal")
 	}
 }
+
+func TestWriteToReadFromCounts(t *testing.T) {
+	f, _ := New(10000, 5)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+
+	var b bytes.Buffer
+	written, err := f.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != int64(binarySize(f.K(), f.M())) || written != int64(b.Len()) {
+		t.Errorf("expected %d byte(s) written, got %d", b.Len(), written)
+	}
+	b.WriteString("tail")
+
+	f2, _ := New(10000, 5)
+	bits := &f2.bits[0]
+	read, err := f2.ReadFrom(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != written {
+		t.Errorf("expected %d byte(s) read, got %d", written, read)
+	}
+	if b.String() != "tail" {
+		t.Errorf("ReadFrom consumed past the end of the filter, left %q", b.String())
+	}
+	if &f2.bits[0] != bits {
+		t.Error("ReadFrom did not reuse bits of the same size")
+	}
+	if !f.IsCompatible(f2) || f2.N() != f.N() {
+		t.Error("Filters not equal")
+	}
+	for _, x := range hashableUint64Values() {
+		if !f2.Contains(x) {
+			t.Error("Filters not equal")
+		}
+	}
+}
+
+func TestReadFromPipe(t *testing.T) {
+	f, _ := New(100000, 5)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		_, err := f.WriteTo(w)
+		w.CloseWithError(err)
+	}()
+
+	f2, _, err := ReadFrom(iotest.OneByteReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range hashableUint64Values() {
+		if !f2.Contains(x) {
+			t.Error("Filters not equal")
+		}
+	}
+}
+
+func TestReadFromTruncated(t *testing.T) {
+	f, _ := New(10000, 5)
+
+	var b bytes.Buffer
+	_, _ = f.WriteTo(&b)
+	b.Truncate(b.Len() - 1)
+
+	if _, _, err := ReadFrom(&b); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestWriteCompressedTo(t *testing.T) {
+	f, _ := New(1000*1000, 5)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+
+	var b bytes.Buffer
+	err := f.WriteCompressedTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() > 2048 {
+		t.Errorf("expected a mostly-empty filter to compress to <= 2048 byte(s), got %d", b.Len())
+	}
+
+	f2 := new(Filter)
+	err = f2.ReadCompressedFrom(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Equal(f2) || f2.N() != f.N() {
+		t.Error("Filters not equal")
+	}
+}
+
+func TestReadCompressedFromCorrupt(t *testing.T) {
+	f, _ := New(1000, 5)
+	f.Add(hashableUint64(7))
+
+	data, _ := f.MarshalBinary()
+	data[binaryHeaderSize] ^= 0x01
+
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, _ = w.Write(data)
+	_ = w.Close()
+
+	err := new(Filter).ReadCompressedFrom(&b)
+	if err == nil || err.Error() != errHash().Error() {
+		t.Errorf("expected %v, got %v", errHash(), err)
+	}
+}
+
+func TestWriteToFileReadFromFile(t *testing.T) {
+	f, _ := New(10000, 5)
+	for i := 0; i < 100; i++ {
+		f.Add(hashableUint64(i))
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "filter.bf")
+	if err := f.WriteToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	// overwriting is atomic, and leaves no temporary file behind
+	if err := f.WriteToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only %s in %s, got %d entries", path, dir, len(entries))
+	}
+
+	f2, err := ReadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Equal(f2) || f2.N() != f.N() {
+		t.Error("the filter read back should equal the one written")
+	}
+}
+
+func TestReadFromFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ReadFromFile(filepath.Join(dir, "missing.bf")); !os.IsNotExist(err) {
+		t.Errorf("expected a not-exist error, got %v", err)
+	}
+
+	path := filepath.Join(dir, "corrupt.bf")
+	if err := os.WriteFile(path, []byte("not a filter"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ReadFromFile(path)
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("expected an error naming %s, got %v", path, err)
+	}
+
+	f, _ := New(10000, 5)
+	if err := f.WriteToFile(filepath.Join(dir, "missing", "filter.bf")); err == nil {
+		t.Error("expected an error writing into a missing directory")
+	}
+}
+
+func TestReadFileTruncated(t *testing.T) {
+	f, _ := New(10000, 5)
+	f.Add(hashableUint64(1))
+	path := filepath.Join(t.TempDir(), "filter.bf.gz")
+	if _, err := f.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	f2, _, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f2.Equal(f) {
+		t.Error("ReadFile should return what WriteFile wrote")
+	}
+
+	// a complete gzip stream of a truncated filter
+	data, _ := f.MarshalBinary()
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, _ = w.Write(data[:len(data)/2])
+	_ = w.Close()
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f2, _, err = ReadFile(path)
+	if err == nil || f2 != nil {
+		t.Errorf("expected an error and no filter reading a truncated file, got %v and %v", f2, err)
+	}
+}
+
+// hostileHeaders are binary headers whose k or m are far beyond what the
+// data behind them holds
+func hostileHeaders() [][]byte {
+	var headers [][]byte
+	for _, h := range []struct {
+		version uint8
+		k, m    uint64
+	}{
+		{binaryVersion, binaryMaxK + 1, 100},
+		{binaryVersion, 1, 1 << 63},
+		{binaryVersionSeeded, 1 << 60, 1 << 62},
+		{binaryVersionSeeded, 1, 1 << 63},
+		{binaryVersion, 1, binaryMaxM}, // within bounds, but not in the data
+	} {
+		data := []byte{h.version}
+		data = append(data, le64(h.k)...)
+		data = append(data, le64(0)...)
+		data = append(data, le64(h.m)...)
+		data = append(data, le64(0)...)
+		headers = append(headers, data)
+	}
+	return headers
+}
+
+func TestReadFromHostileHeader(t *testing.T) {
+	dir := t.TempDir()
+	for i, data := range hostileHeaders() {
+		_, _, err := ReadFrom(bytes.NewReader(data))
+		if err == nil {
+			t.Errorf("header %d: expected an error from ReadFrom", i)
+		} else if i < 4 && err.Error() != errSize().Error() {
+			t.Errorf("header %d: expected %v, got %v", i, errSize(), err)
+		}
+		if err := new(Filter).UnmarshalBinary(data); err == nil {
+			t.Errorf("header %d: expected an error from UnmarshalBinary", i)
+		}
+
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		_, _ = w.Write(data)
+		_ = w.Close()
+		if err := new(Filter).ReadCompressedFrom(bytes.NewReader(b.Bytes())); err == nil {
+			t.Errorf("header %d: expected an error from ReadCompressedFrom", i)
+		}
+		path := filepath.Join(dir, "hostile.bf.gz")
+		if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := ReadFile(path); err == nil {
+			t.Errorf("header %d: expected an error from ReadFile", i)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadFromFile(path); err == nil {
+			t.Errorf("header %d: expected an error from ReadFromFile", i)
+		}
+	}
+}
+
+func TestReadFromLarge(t *testing.T) {
+	// more words than a stream is trusted with up front
+	f, _ := New(64*binaryTrustedWords*3+1, 3)
+	for i := 0; i < 1000; i++ {
+		f.Add(hashableUint64(i))
+	}
+	var b bytes.Buffer
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	f2, _, err := ReadFrom(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f2.Equal(f) || f2.N() != f.N() {
+		t.Error("a large filter should survive WriteTo and ReadFrom")
+	}
+}
+
+func FuzzReadFrom(f *testing.F) {
+	for _, data := range hostileHeaders() {
+		f.Add(data)
+	}
+	seed, _ := New(100, 3)
+	data, _ := seed.MarshalBinary()
+	f.Add(data)
+	seeded, _ := NewWithSeed(100, 3, 42)
+	data, _ = seeded.MarshalBinary()
+	f.Add(data)
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bf, n, err := ReadFrom(bytes.NewReader(data))
+		if n > int64(len(data)) {
+			t.Errorf("read %d byte(s) of %d", n, len(data))
+		}
+		if err != nil {
+			if bf != nil {
+				t.Error("a filter should not be returned with an error")
+			}
+		} else if verr := bf.Validate(); verr != nil {
+			t.Errorf("ReadFrom accepted an invalid filter: %v", verr)
+		}
+
+		into := new(Filter)
+		if err := into.UnmarshalBinary(data); err == nil {
+			if verr := into.Validate(); verr != nil {
+				t.Errorf("UnmarshalBinary accepted an invalid filter: %v", verr)
+			}
+		}
+	})
+}
@@ -0,0 +1,76 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"bufio"
+	"io"
+)
+
+// the convenience helpers always hash with 64-bit FNV-1a, so filters built
+// with them stay comparable across processes and after serialization, and
+// AddString(s) is interchangeable with AddBytes([]byte(s))
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// fnv64a is hash/fnv's New64a, inlined so that hashing allocates nothing
+func fnv64a[T []byte | string](b T) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(b); i++ {
+		h ^= uint64(b[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// AddBytes adds b to the filter, hashed with 64-bit FNV-1a
+func (f *Filter) AddBytes(b []byte) {
+	f.AddRaw(fnv64a(b))
+}
+
+// ContainsBytes tests if f contains b, hashed with 64-bit FNV-1a
+// false: f definitely does not contain b
+// true:  f maybe contains b
+func (f *Filter) ContainsBytes(b []byte) bool {
+	return f.ContainsRaw(fnv64a(b))
+}
+
+// AddString adds s to the filter, hashed with 64-bit FNV-1a
+func (f *Filter) AddString(s string) {
+	f.AddRaw(fnv64a(s))
+}
+
+// ContainsString tests if f contains s, hashed with 64-bit FNV-1a
+// false: f definitely does not contain s
+// true:  f maybe contains s
+func (f *Filter) ContainsString(s string) bool {
+	return f.ContainsRaw(fnv64a(s))
+}
+
+// addFromReaderMaxLine is the longest line AddFromReader accepts
+const addFromReaderMaxLine = 64 << 20
+
+// AddFromReader adds each line of r to the filter, hashed with 64-bit
+// FNV-1a like AddBytes, without its line ending ("\n" or "\r\n")
+//
+// Lines may be up to 64 MiB long. It returns how many were added, including
+// those before any read error, which it returns as is
+func (f *Filter) AddFromReader(r io.Reader) (count uint64, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, addFromReaderMaxLine)
+	for scanner.Scan() {
+		f.AddBytes(scanner.Bytes())
+		count++
+	}
+	return count, scanner.Err()
+}
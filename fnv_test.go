@@ -0,0 +1,155 @@
+package bloomfilter
+
+import (
+	"errors"
+	"hash/fnv"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAddBytes(t *testing.T) {
+	f, _ := New(10000, 5)
+	f.AddBytes([]byte("x"))
+
+	if !f.ContainsBytes([]byte("x")) {
+		t.Error("definitely does not contain x, but it should")
+	}
+	if f.ContainsBytes([]byte("an unrelated key")) {
+		t.Error("may contain an unrelated key, but almost surely should not")
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("x"))
+	if !f.Contains(h) {
+		t.Error("AddBytes is not hashing with FNV-1a")
+	}
+}
+
+func TestAddString(t *testing.T) {
+	f, _ := New(10000, 5)
+	f.AddString("x")
+
+	if !f.ContainsString("x") || !f.ContainsBytes([]byte("x")) {
+		t.Error("definitely does not contain x, but it should")
+	}
+	if f.ContainsString("an unrelated key") {
+		t.Error("may contain an unrelated key, but almost surely should not")
+	}
+
+	f.AddBytes([]byte("y"))
+	if !f.ContainsString("y") {
+		t.Error("definitely does not contain y, but it should")
+	}
+}
+
+func TestAddFromReader(t *testing.T) {
+	f, _ := New(10000, 5)
+	long := strings.Repeat("x", 1<<20)
+	count, err := f.AddFromReader(strings.NewReader("a\nbb\r\nccc\n" + long + "\nlast"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 || f.N() != 5 {
+		t.Errorf("expected 5 keys, got count=%d n=%d", count, f.N())
+	}
+	for _, key := range []string{"a", "bb", "ccc", long, "last"} {
+		if !f.ContainsBytes([]byte(key)) {
+			t.Errorf("definitely does not contain %.10q, but it should", key)
+		}
+	}
+	if f.ContainsString("bb\r") {
+		t.Error("line endings should not be part of the key")
+	}
+}
+
+func TestAddFromReaderError(t *testing.T) {
+	f, _ := New(10000, 5)
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("a\nb\n"), &errorReader{err: errRead})
+	count, err := f.AddFromReader(r)
+	if !errors.Is(err, errRead) {
+		t.Errorf("expected %v, got %v", errRead, err)
+	}
+	if count != 2 {
+		t.Errorf("expected the 2 keys before the error, got %d", count)
+	}
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestAddStringAllocs(t *testing.T) {
+	f, _ := New(10000, 5)
+	allocs := testing.AllocsPerRun(100, func() {
+		f.AddString("x")
+		f.ContainsString("x")
+	})
+	if allocs != 0 {
+		t.Errorf("expected 0 allocations, got %f", allocs)
+	}
+}
+
+func BenchmarkAddStringX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		bf.AddString("some key")
+	}
+}
+
+func BenchmarkContainsStringX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	bf.AddString("some key")
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		bf.ContainsString("some key")
+	}
+}
+
+func TestAddRaw(t *testing.T) {
+	f, _ := New(10000, 5)
+	f.AddRaw(0xdeadbeef)
+	if !f.ContainsRaw(0xdeadbeef) || !f.Contains(hashableUint64(0xdeadbeef)) {
+		t.Error("definitely does not contain 0xdeadbeef, but it should")
+	}
+	f.Add(hashableUint64(7))
+	if !f.ContainsRaw(7) {
+		t.Error("definitely does not contain 7, but it should")
+	}
+}
+
+func BenchmarkContainsFNVX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	key := []byte("some key")
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		h := fnv.New64a()
+		_, _ = h.Write(key)
+		bf.Contains(h)
+	}
+}
+
+func BenchmarkContainsRawX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("some key"))
+	raw := h.Sum64()
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		bf.ContainsRaw(raw)
+	}
+}
@@ -0,0 +1,126 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// fold ORs every set bit of src into dst at its position modulo dst.m,
+// which is where it would have landed had dst.m divided src.m all along;
+// the caller must hold both locks
+func fold(dst, src *Filter) {
+	for w := range src.bits {
+		word := src.bits[w].Load()
+		for word != 0 {
+			i := (uint64(w)<<6 + uint64(bits.TrailingZeros64(word))) % dst.m
+			dst.bits[i>>6].Or(1 << uint(i&0x3f))
+			word &= word - 1
+		}
+	}
+}
+
+// verifyFoldable checks that f and f2 share keys and that the smaller m
+// divides the larger; the caller must hold both locks
+func (f *Filter) verifyFoldable(f2 *Filter) error {
+	small, large := min(f.M(), f2.M()), max(f.M(), f2.M())
+	e := make([]string, 3)
+	if large%small != 0 {
+		e[0] = fmt.Sprintf("M=%d does not divide M=%d", small, large)
+	}
+	if f.K() != f2.K() {
+		e[1] = fmt.Sprintf("mismatched key counts K=%d and K=%d", f.K(), f2.K())
+	} else if noBranchCompareUint64s(f.keys, f2.keys) != 0 {
+		e[2] = "mismatched keys"
+	}
+	if e[0] != "" || e[1] != "" || e[2] != "" {
+		return errIncompatibleBloomFilters(e)
+	}
+	return nil
+}
+
+// UnionFold merges f and f2 into a new Filter out of the smaller of their
+// sizes, folding the bits of the larger one down onto it
+//
+// f and f2 must share keys, and one M() must be a multiple of the other.
+// The result has the false positive rate of a filter of the smaller size
+// holding both sets, which is higher than that of the larger input
+func (f *Filter) UnionFold(f2 *Filter) (out *Filter, err error) {
+	defer rlockPair(f, f2)()
+	if err := f.verifyFoldable(f2); err != nil {
+		return nil, err
+	}
+	out, err = NewWithKeys(min(f.M(), f2.M()), f.keys)
+	if err != nil {
+		return nil, err
+	}
+	out.positions = f.positions
+	fold(out, f)
+	fold(out, f2)
+	out.n.Store(saturatingAdd(f.n.Load(), f2.n.Load()))
+	return out, nil
+}
+
+// Downsample f into a new Filter out of M()/factor bits, with the same keys
+// and N(), by folding the bits of f down onto it, such as for sending over
+// a slow link; factor must divide M(), leaving at least MMin bits
+//
+// Positions modulo M() are the same modulo any divisor of it, so every item
+// f contains, out contains. out holds the items at a factor times higher
+// density, so its false positive probability is that of a filter of the
+// smaller size holding all of N(): much higher than that of f
+func (f *Filter) Downsample(factor uint64) (out *Filter, err error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if factor == 0 || f.m%factor != 0 || f.m/factor < MMin {
+		return nil, errDownsample(f.m, factor)
+	}
+	out, err = NewWithKeys(f.m/factor, f.keys)
+	if err != nil {
+		return nil, err
+	}
+	out.positions = f.positions
+	fold(out, f)
+	out.n.Store(f.n.Load())
+	return out, nil
+}
+
+// Resize f to a new Filter out of newM bits, with the same keys and N()
+//
+// Without the original items, bits cannot be re-hashed, only remapped, so
+// newM must be a multiple of M(): each set bit i of f could have come from
+// any of i, i+M(), i+2*M(), ... in out, so all of them are set. out has the
+// same fill ratio and false positive probability as f to begin with, but
+// fills up more slowly from there on. To shrink a filter by a divisor of
+// M(), use UnionFold
+func (f *Filter) Resize(newM uint64) (out *Filter, err error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if newM == 0 || newM%f.m != 0 {
+		return nil, errResize(f.m, newM)
+	}
+	out, err = NewWithKeys(newM, f.keys)
+	if err != nil {
+		return nil, err
+	}
+	out.positions = f.positions
+	for w := range f.bits {
+		word := f.bits[w].Load()
+		for word != 0 {
+			for i := uint64(w)<<6 + uint64(bits.TrailingZeros64(word)); i < newM; i += f.m {
+				out.bits[i>>6].Or(1 << uint(i&0x3f))
+			}
+			word &= word - 1
+		}
+	}
+	out.n.Store(f.n.Load())
+	return out, nil
+}
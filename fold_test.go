@@ -0,0 +1,144 @@
+package bloomfilter
+
+import (
+	"testing"
+)
+
+func TestUnionFold(t *testing.T) {
+	small, _ := New(1000, 5)
+	large, _ := NewWithKeys(2000, small.keys)
+
+	for i := 0; i < 50; i++ {
+		small.Add(hashableUint64(i))
+	}
+	for i := 50; i < 100; i++ {
+		large.Add(hashableUint64(i))
+	}
+
+	for _, out := range []func() (*Filter, error){
+		func() (*Filter, error) { return small.UnionFold(large) },
+		func() (*Filter, error) { return large.UnionFold(small) },
+	} {
+		f, err := out()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.M() != small.M() || !f.IsCompatible(small) {
+			t.Errorf("expected a filter compatible with the smaller input")
+		}
+		if f.N() != 100 {
+			t.Errorf("expected N()=100, got %d", f.N())
+		}
+		for i := 0; i < 100; i++ {
+			if !f.Contains(hashableUint64(i)) {
+				t.Fatal("folded union definitely does not contain ", i,
+					", but it should")
+			}
+		}
+	}
+}
+
+func TestUnionFoldInvalid(t *testing.T) {
+	f, _ := New(1000, 5)
+	f2, _ := NewWithKeys(1500, f.keys)
+	if _, err := f.UnionFold(f2); err == nil {
+		t.Error("expected an error folding non-divisible sizes")
+	}
+
+	f3, _ := New(2000, 5)
+	if _, err := f.UnionFold(f3); err == nil {
+		t.Error("expected an error folding mismatched keys")
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	f, _ := New(8000, 5)
+	for i := 0; i < 200; i++ {
+		f.Add(hashableUint64(i))
+	}
+
+	for _, factor := range []uint64{1, 2, 8, 4000} {
+		out, err := f.Downsample(factor)
+		if err != nil {
+			t.Fatalf("factor=%d: %v", factor, err)
+		}
+		if out.M() != f.M()/factor || out.K() != f.K() || out.N() != f.N() {
+			t.Errorf("factor=%d: expected (m=%d, k=%d, n=%d), got (m=%d, k=%d, n=%d)",
+				factor, f.M()/factor, f.K(), f.N(), out.M(), out.K(), out.N())
+		}
+		for i := 0; i < 200; i++ {
+			if !out.Contains(hashableUint64(i)) {
+				t.Fatalf("factor=%d: downsampled filter definitely does not contain %d, but it should", factor, i)
+			}
+		}
+		if factor > 1 && out.FalsePositiveProbability() <= f.FalsePositiveProbability() {
+			t.Errorf("factor=%d: expected a higher false positive probability", factor)
+		}
+	}
+
+	for _, factor := range []uint64{0, 3, 8000} {
+		if _, err := f.Downsample(factor); err == nil {
+			t.Errorf("factor=%d: expected an error", factor)
+		}
+	}
+}
+
+func TestDownsamplePositionFunc(t *testing.T) {
+	f, _ := NewWithPositionFunc(1000, 4, kirschMitzenmacher)
+	for i := 0; i < 50; i++ {
+		f.Add(hashableUint64(i))
+	}
+	out, err := f.Downsample(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if !out.Contains(hashableUint64(i)) {
+			t.Fatal("downsampled filter definitely does not contain ", i,
+				", but it should")
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	f, _ := New(1000, 5)
+	for i := 0; i < 50; i++ {
+		f.Add(hashableUint64(i))
+	}
+
+	out, err := f.Resize(2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.M() != 2000 || out.K() != f.K() || out.N() != f.N() {
+		t.Errorf("expected (m=2000, k=%d, n=%d), got (m=%d, k=%d, n=%d)",
+			f.K(), f.N(), out.M(), out.K(), out.N())
+	}
+	if out.Count() != 2*f.Count() {
+		t.Errorf("expected each bit spread twice, got %d bit(s) from %d", out.Count(), f.Count())
+	}
+	for i := 0; i < 50; i++ {
+		if !out.Contains(hashableUint64(i)) {
+			t.Fatal("resized filter definitely does not contain ", i,
+				", but it should")
+		}
+	}
+
+	// folding back down is lossless
+	back, err := out.UnionFold(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !back.Equal(f) {
+		t.Error("folding the resized filter back should give the original")
+	}
+}
+
+func TestResizeInvalid(t *testing.T) {
+	f, _ := New(1000, 5)
+	for _, m := range []uint64{0, 1500, 999} {
+		if _, err := f.Resize(m); err == nil {
+			t.Errorf("m=%d: expected an error", m)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+type gobContainer struct {
+	Name   string
+	Filter *Filter
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	f, _ := New(1000, 4)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(gobContainer{Name: "test", Filter: f})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out gobContainer
+	err = gob.NewDecoder(&buf).Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f2 := out.Filter
+	if out.Name != "test" {
+		t.Errorf("expected Name=%q, got %q", "test", out.Name)
+	}
+	if f2.M() != f.M() || f2.K() != f.K() || f2.N() != f.N() {
+		t.Errorf("expected (m=%d, k=%d, n=%d), got (m=%d, k=%d, n=%d)",
+			f.M(), f.K(), f.N(), f2.M(), f2.K(), f2.N())
+	}
+	for _, x := range append(hashableUint64Values(), hashableUint64NotValues()...) {
+		if f.Contains(x) != f2.Contains(x) {
+			t.Error("decoded filter disagrees with the original on ", x)
+		}
+	}
+}
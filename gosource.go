@@ -0,0 +1,61 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"io"
+)
+
+// goSourceWordsPerLine is how many uint64 literals WriteGoSource puts on a
+// line
+const goSourceWordsPerLine = 4
+
+// WriteGoSource writes a Go variable declaration named varName to w, which
+// rebuilds f with NewFromBitSet from literal m, keys and bits, for baking
+// a precomputed filter into a binary
+//
+// The declaration refers to this package as bloomfilter, so the file it is
+// pasted into must import it under that name. The rebuilt filter has an N()
+// of 0, and the default bit positions even if f has a PositionFunc
+func (f *Filter) WriteGoSource(w io.Writer, varName string) error {
+	if !token.IsIdentifier(varName) {
+		return errVarName(varName)
+	}
+
+	f.lock.Lock()
+	bits := f.getBits()
+	f.lock.Unlock()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "// %s is a Bloom filter of m=%d, k=%d, n=%d\n", varName, f.m, len(f.keys), f.N())
+	fmt.Fprintf(bw, "var %s = func() *bloomfilter.Filter {\n", varName)
+	fmt.Fprintf(bw, "\tf, err := bloomfilter.NewFromBitSet(\n\t\t%d,\n", f.m)
+	writeGoUint64s(bw, f.keys)
+	writeGoUint64s(bw, bits)
+	fmt.Fprintf(bw, "\t)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n\treturn f\n}()\n")
+	return bw.Flush()
+}
+
+// writeGoUint64s writes a []uint64 literal of words as an argument
+func writeGoUint64s(w io.Writer, words []uint64) {
+	fmt.Fprintf(w, "\t\t[]uint64{")
+	for i, word := range words {
+		if i%goSourceWordsPerLine == 0 {
+			fmt.Fprintf(w, "\n\t\t\t")
+		} else {
+			fmt.Fprintf(w, " ")
+		}
+		fmt.Fprintf(w, "0x%016x,", word)
+	}
+	fmt.Fprintf(w, "\n\t\t},\n")
+}
@@ -0,0 +1,92 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+func TestWriteGoSource(t *testing.T) {
+	f, _ := New(1000, 4)
+	for i := 0; i < 50; i++ {
+		f.Add(hashableUint64(i))
+	}
+
+	var b bytes.Buffer
+	if err := f.WriteGoSource(&b, "allowlist"); err != nil {
+		t.Fatal(err)
+	}
+	src := "package p\n\nimport \"github.com/farmersedgeinc/bloomfilter\"\n\n" + b.String()
+
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+	if string(formatted) != src {
+		t.Errorf("generated source is not gofmt-ed:\n%s", b.String())
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// evaluate the arguments of the NewFromBitSet call by hand
+	var args []ast.Expr
+	ast.Inspect(file, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "NewFromBitSet" {
+				args = call.Args
+			}
+		}
+		return true
+	})
+	if len(args) != 3 {
+		t.Fatalf("expected a NewFromBitSet call with 3 arguments, got %d", len(args))
+	}
+	m := parseGoUint64(t, args[0])
+	var lists [2][]uint64
+	for i, arg := range args[1:] {
+		for _, elt := range arg.(*ast.CompositeLit).Elts {
+			lists[i] = append(lists[i], parseGoUint64(t, elt))
+		}
+	}
+
+	f2, err := NewFromBitSet(m, lists[0], lists[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Equal(f2) {
+		t.Error("the filter rebuilt from the Go source should equal the original")
+	}
+}
+
+func parseGoUint64(t *testing.T, expr ast.Expr) uint64 {
+	t.Helper()
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		t.Fatalf("expected an integer literal, got %T", expr)
+	}
+	v, err := strconv.ParseUint(lit.Value, 0, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestWriteGoSourceInvalidName(t *testing.T) {
+	f, _ := New(1000, 4)
+	var b bytes.Buffer
+	for _, name := range []string{"", "1filter", "my-filter", "func"} {
+		if err := f.WriteGoSource(&b, name); err == nil {
+			t.Errorf("expected an error for the name %q", name)
+		}
+	}
+	if b.Len() != 0 {
+		t.Error("nothing should be written for an invalid name")
+	}
+}
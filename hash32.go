@@ -0,0 +1,51 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import "hash"
+
+// widen32 spreads a 32-bit hash over 64 bits with fmix64, so that the bit
+// positions derived from it are well-distributed
+//
+// A filter holds at most 2^32 distinct hashes this way, like any 32-bit hash
+func widen32(sum uint32) uint64 {
+	return fmix64(uint64(sum))
+}
+
+// fmix64 is the MurmurHash3 64-bit finalizer, a bijection in which every
+// bit of x affects every bit of the result:
+//
+//	x ^= x >> 33
+//	x *= 0xff51afd7ed558ccd
+//	x ^= x >> 33
+//	x *= 0xc4ceb9fe1a85ec53
+//	x ^= x >> 33
+func fmix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Add32 adds an item hashed by a 32-bit hash, v, to the filter, widening
+// the hash with widen32; Add32(v) is AddRaw(widen32(v.Sum32()))
+func (f *Filter) Add32(v hash.Hash32) {
+	f.AddRaw(widen32(v.Sum32()))
+}
+
+// Contains32 tests if f contains an item hashed by a 32-bit hash, v, see
+// Add32
+// false: f definitely does not contain value v
+// true:  f maybe contains value v
+func (f *Filter) Contains32(v hash.Hash32) bool {
+	return f.ContainsRaw(widen32(v.Sum32()))
+}
@@ -0,0 +1,56 @@
+package bloomfilter
+
+import (
+	"hash/crc32"
+	"strconv"
+	"testing"
+)
+
+func crc32Of(s string) *crc32Hash {
+	h := crc32.NewIEEE()
+	_, _ = h.Write([]byte(s))
+	return &crc32Hash{h.Sum32()}
+}
+
+// crc32Hash is a finished CRC-32, so lookups need not hash again
+type crc32Hash struct {
+	sum uint32
+}
+
+func (h *crc32Hash) Write(p []byte) (int, error) { panic("Unimplemented") }
+func (h *crc32Hash) Sum(b []byte) []byte         { panic("Unimplemented") }
+func (h *crc32Hash) Reset()                      { panic("Unimplemented") }
+func (h *crc32Hash) Size() int                   { return 4 }
+func (h *crc32Hash) BlockSize() int              { return 1 }
+func (h *crc32Hash) Sum32() uint32               { return h.sum }
+
+func TestAdd32(t *testing.T) {
+	const n = 1000
+	f, _ := NewOptimal(n, 0.01)
+	for i := 0; i < n; i++ {
+		f.Add32(crc32Of("key-" + strconv.Itoa(i)))
+	}
+	for i := 0; i < n; i++ {
+		if !f.Contains32(crc32Of("key-" + strconv.Itoa(i))) {
+			t.Errorf("definitely does not contain key-%d, but it should", i)
+		}
+	}
+
+	h := crc32.NewIEEE()
+	_, _ = h.Write([]byte("key-0"))
+	if !f.Contains32(h) {
+		t.Error("Contains32 should accept any hash.Hash32")
+	}
+
+	falsePositives := 0
+	const probes = 100000
+	for i := n; i < n+probes; i++ {
+		if f.Contains32(crc32Of("key-" + strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	rate, expected := float64(falsePositives)/probes, f.FalsePositiveProbability()
+	if rate > 1.5*expected {
+		t.Errorf("expected a false positive rate near %g, got %g", expected, rate)
+	}
+}
@@ -0,0 +1,88 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import "hash"
+
+// ImmutableFilter is a read-only snapshot of a Filter, see Freeze
+//
+// It has no way to add elements, and as its bits never change, Contains
+// reads them without locking or atomics, so it is safe and cheap to share
+// among any number of goroutines
+type ImmutableFilter struct {
+	bits      []uint64
+	keys      []uint64
+	m         uint64
+	n         uint64
+	positions PositionFunc
+}
+
+// Freeze f into an ImmutableFilter holding a copy of its current bits;
+// later changes to f do not affect it
+func (f *Filter) Freeze() *ImmutableFilter {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return &ImmutableFilter{
+		bits:      f.getBits(),
+		keys:      f.keys, // immutable, so safe to share
+		m:         f.m,
+		n:         f.n.Load(),
+		positions: f.positions,
+	}
+}
+
+// M is the size of Bloom filter, in bits
+func (f *ImmutableFilter) M() uint64 {
+	return f.m
+}
+
+// K is the count of keys
+func (f *ImmutableFilter) K() uint64 {
+	return uint64(len(f.keys))
+}
+
+// N is how many elements had been inserted when the filter was frozen
+func (f *ImmutableFilter) N() uint64 {
+	return f.n
+}
+
+// FalsePositiveProbability is the upper-bound probability of false
+// positives, see Filter.FalsePositiveProbability
+func (f *ImmutableFilter) FalsePositiveProbability() float64 {
+	return falsePositiveProbability(f.M(), f.K(), f.N())
+}
+
+// Contains tests if f contains v
+// false: f definitely does not contain value v
+// true:  f maybe contains value v
+func (f *ImmutableFilter) Contains(v hash.Hash64) bool {
+	return f.ContainsRaw(v.Sum64())
+}
+
+// ContainsRaw tests if f contains the item whose 64-bit hash is rawHash,
+// see Filter.AddRaw
+func (f *ImmutableFilter) ContainsRaw(rawHash uint64) bool {
+	if f.m == 0 || len(f.keys) == 0 {
+		return false
+	}
+	r := uint64(1)
+	if f.positions != nil {
+		for _, i := range f.positions(rawHash, len(f.keys)) {
+			i %= f.m
+			r &= (f.bits[i>>6] >> uint(i&0x3f)) & 1
+		}
+		return uint64ToBool(r)
+	}
+	for _, key := range f.keys {
+		i := (rawHash ^ key) % f.m
+		r &= (f.bits[i>>6] >> uint(i&0x3f)) & 1
+	}
+	return uint64ToBool(r)
+}
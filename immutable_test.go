@@ -0,0 +1,69 @@
+package bloomfilter
+
+import "testing"
+
+func TestFreeze(t *testing.T) {
+	f, _ := New(10000, 5)
+	for i := 0; i < 100; i++ {
+		f.Add(hashableUint64(i))
+	}
+	frozen := f.Freeze()
+
+	if frozen.M() != f.M() || frozen.K() != f.K() || frozen.N() != f.N() {
+		t.Errorf("expected (m=%d, k=%d, n=%d), got (m=%d, k=%d, n=%d)",
+			f.M(), f.K(), f.N(), frozen.M(), frozen.K(), frozen.N())
+	}
+	if frozen.FalsePositiveProbability() != f.FalsePositiveProbability() {
+		t.Errorf("expected p=%g, got %g",
+			f.FalsePositiveProbability(), frozen.FalsePositiveProbability())
+	}
+	for i := 0; i < 100; i++ {
+		if !frozen.Contains(hashableUint64(i)) {
+			t.Errorf("frozen filter should contain %d", i)
+		}
+	}
+	for i := 100; i < 1100; i++ {
+		if frozen.Contains(hashableUint64(i)) != f.Contains(hashableUint64(i)) {
+			t.Errorf("frozen filter should agree with its source on %d", i)
+		}
+	}
+
+	f.Add(hashableUint64(100000))
+	if frozen.N() != 100 {
+		t.Errorf("frozen filter should not see later adds, got n=%d", frozen.N())
+	}
+}
+
+func TestFreezePositionFunc(t *testing.T) {
+	f, err := NewWithPositionFunc(1000, 4, kirschMitzenmacher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Add(hashableUint64(7))
+	if !f.Freeze().Contains(hashableUint64(7)) {
+		t.Error("frozen filter should use the position func of its source")
+	}
+}
+
+func BenchmarkContainsX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	bf.Add(hashableUint64(1))
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Contains(hashableUint64(1))
+	}
+}
+
+func BenchmarkContainsImmutableX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(10000, 5)
+	bf.Add(hashableUint64(1))
+	frozen := bf.Freeze()
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		frozen.Contains(hashableUint64(1))
+	}
+}
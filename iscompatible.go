@@ -28,11 +28,7 @@ func noBranchCompareUint64s(b0, b1 []uint64) uint64 {
 
 // IsCompatible is true if f and f2 can be Union()ed together
 func (f *Filter) IsCompatible(f2 *Filter) bool {
-	f.lock.RLock()
-	defer f.lock.RUnlock()
-
-	f2.lock.RLock()
-	defer f2.lock.RUnlock()
+	defer rlockPair(f, f2)()
 	return f.isCompatible(f2)
 }
 
@@ -43,24 +39,39 @@ func (f *Filter) isCompatible(f2 *Filter) bool {
 		noBranchCompareUint64s(f.keys, f2.keys) == 0
 }
 
+// verifyCompatible explains why f and f2 cannot be Union()ed together; the
+// caller must hold both locks, as RLock()ing them again could deadlock
+// behind a waiting Lock()
 func (f *Filter) verifyCompatible(f2 *Filter) error {
-	f.lock.RLock()
-	defer f.lock.RUnlock()
-
-	f2.lock.RLock()
-	defer f2.lock.RUnlock()
 	if f.isCompatible(f2) {
 		return nil
 	}
 	e := make([]string, 3)
 	if f.M() != f2.M() {
-		e[0] = fmt.Sprintf("M=%d and M=%d", f.K(), f2.K())
+		e[0] = fmt.Sprintf("mismatched sizes M=%d and M=%d", f.M(), f2.M())
 	}
 	if f.K() != f2.K() {
-		e[1] = fmt.Sprintf("K=%d and K=%d", f.K(), f2.K())
-	}
-	if noBranchCompareUint64s(f.keys, f2.keys) != 0 {
-		e[2] = "Mismatched Keys"
+		e[1] = fmt.Sprintf("mismatched key counts K=%d and K=%d", f.K(), f2.K())
+	} else if noBranchCompareUint64s(f.keys, f2.keys) != 0 {
+		e[2] = "mismatched keys"
 	}
 	return errIncompatibleBloomFilters(e)
 }
+
+// Equal is true if f and f2 are compatible and have exactly the same bits
+// set, regardless of N()
+func (f *Filter) Equal(f2 *Filter) bool {
+	if f == f2 {
+		return true
+	}
+
+	defer rlockPair(f, f2)()
+	if !f.isCompatible(f2) {
+		return false
+	}
+	r := uint64(0)
+	for i := range f.bits {
+		r |= f.bits[i].Load() ^ f2.bits[i].Load()
+	}
+	return r == 0
+}
@@ -0,0 +1,127 @@
+package bloomfilter
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEqual(t *testing.T) {
+	f, _ := New(10000, 5)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+
+	f2, err := f.Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Equal(f2) || !f2.Equal(f) {
+		t.Error("a filter should equal its Copy()")
+	}
+
+	f2.Add(hashableUint64(0xabcdef))
+	if f.Equal(f2) || f2.Equal(f) {
+		t.Error("filters should differ after an extra Add()")
+	}
+
+	f3, _ := NewWithKeys(20000, f.keys)
+	if f.Equal(f3) {
+		t.Error("filters of different sizes should not be equal")
+	}
+	f4, _ := New(10000, 6)
+	if f.Equal(f4) {
+		t.Error("filters with different keys should not be equal")
+	}
+}
+
+func TestUnionConcurrentCopy(t *testing.T) {
+	f, _ := New(10000, 5)
+	f2, _ := f.NewCompatible()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if _, err := f.Union(f2); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := f.UnionInPlace(f2); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				f2.Add(hashableUint64(g*200 + i))
+				if _, err := f.Copy(); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := f2.Copy(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("deadlocked running Union alongside Copy")
+	}
+}
+
+func TestIncompatibleError(t *testing.T) {
+	f, _ := New(1000, 5)
+	f2, _ := NewWithKeys(2000, f.keys)
+
+	_, err := f.Union(f2)
+	if err == nil {
+		t.Fatal("expected an error unioning filters of different sizes")
+	}
+	if s := err.Error(); !strings.Contains(s, "M=1000") || !strings.Contains(s, "M=2000") ||
+		strings.Contains(s, "mismatched key") {
+		t.Errorf("expected only the mismatched sizes in the error, got %q", s)
+	}
+
+	f3, _ := New(1000, 6)
+	_, err = f.Union(f3)
+	if err == nil || !strings.Contains(err.Error(), "mismatched key counts K=5 and K=6") {
+		t.Errorf("expected the mismatched key counts in the error, got %v", err)
+	}
+
+	f4, _ := New(1000, 5)
+	_, err = f.Union(f4)
+	if err == nil || !strings.Contains(err.Error(), "mismatched keys") {
+		t.Errorf("expected the mismatched keys in the error, got %v", err)
+	}
+}
+
+func TestIncompatibleErrorHint(t *testing.T) {
+	f, _ := NewWithSeed(1000, 5, 1)
+	f2, _ := NewWithSeed(1000, 5, 2)
+
+	_, err := f.Union(f2)
+	if !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("expected ErrIncompatible, got %v", err)
+	}
+	for _, hint := range []string{"cannot be rehashed without the original items", "NewCompatible", "NewWithKeys", "NewWithSeed"} {
+		if !strings.Contains(err.Error(), hint) {
+			t.Errorf("expected the error to mention %q, got %q", hint, err)
+		}
+	}
+}
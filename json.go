@@ -0,0 +1,92 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+)
+
+// jsonFilter is the JSON shape of a Filter, with bits as base64 of the
+// little endian words
+type jsonFilter struct {
+	M    uint64   `json:"m"`
+	K    uint64   `json:"k"`
+	Keys []uint64 `json:"keys"`
+	N    uint64   `json:"n"`
+	Bits []byte   `json:"bits"`
+}
+
+// MarshalJSON conforms to json.Marshaler
+func (f *Filter) MarshalJSON() ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	out := jsonFilter{
+		M:    f.m,
+		K:    f.K(),
+		Keys: f.keys,
+		N:    f.n.Load(),
+		Bits: make([]byte, 0, len(f.bits)*Uint64Bytes),
+	}
+	for i := range f.bits {
+		out.Bits = binary.LittleEndian.AppendUint64(out.Bits, f.bits[i].Load())
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON overwrites f with the filter decoded from data
+// conforms to json.Unmarshaler
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	var in jsonFilter
+	err := json.Unmarshal(data, &in)
+	if err != nil {
+		return err
+	}
+
+	if in.K != uint64(len(in.Keys)) {
+		return errJSONK(in.K, len(in.Keys))
+	}
+
+	// bounded as binary headers are, before anything is allocated from them
+	err = checkBinaryHeader(in.K, in.M)
+	if err != nil {
+		return err
+	}
+	size := int((in.M + 63) / 64 * Uint64Bytes)
+	if len(in.Bits) != size {
+		return errJSONBits(size, len(in.Bits))
+	}
+
+	keys, err := newKeysCopy(in.Keys)
+	if err != nil {
+		return err
+	}
+
+	bits, err := newBits(in.M)
+	if err != nil {
+		return err
+	}
+	for i := range bits {
+		bits[i].Store(binary.LittleEndian.Uint64(in.Bits[i*Uint64Bytes:]))
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.m = in.M
+	f.n.Store(in.N)
+	f.keys = keys
+	f.seed, f.seeded = 0, false
+	f.pooled = false
+	f.bits = bits
+	f.shared.Store(false)
+	f.maskTail()
+	return nil
+}
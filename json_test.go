@@ -0,0 +1,54 @@
+package bloomfilter
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	f, _ := New(1000, 4)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f2 := new(Filter)
+	err = json.Unmarshal(data, f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !f.Equal(f2) || f2.N() != f.N() {
+		t.Error("Filters not equal")
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	tests := []string{
+		// 2 words are needed for m=100, only 1 given
+		`{"m":100,"k":2,"keys":[1,2],"n":0,"bits":"AAAAAAAAAAA="}`,
+		`{"m":100,"k":3,"keys":[1,2],"n":0,"bits":"AAAAAAAAAAAAAAAAAAAAAA=="}`,
+		`{"m":100,"k":2,"keys":[1,1],"n":0,"bits":"AAAAAAAAAAAAAAAAAAAAAA=="}`,
+		`{"m":1,"k":2,"keys":[1,2],"n":0,"bits":"AAAAAAAAAAA="}`,
+		// m past binaryMaxM, too large to allocate or wrapping to no words
+		`{"m":4611686018427387904,"k":1,"keys":[5],"n":0,"bits":""}`,
+		`{"m":18446744073709551615,"k":1,"keys":[5],"n":0,"bits":""}`,
+		// within bounds, but the bits are not given
+		`{"m":1099511627776,"k":1,"keys":[5],"n":0,"bits":""}`,
+	}
+
+	for _, test := range tests {
+		if err := json.Unmarshal([]byte(test), new(Filter)); err == nil {
+			t.Errorf("%s: expected an error", test)
+		}
+	}
+
+	valid := `{"m":100,"k":2,"keys":[1,2],"n":0,"bits":"AAAAAAAAAAAAAAAAAAAAAA=="}`
+	if err := json.Unmarshal([]byte(valid), new(Filter)); err != nil {
+		t.Errorf("%s: %v", valid, err)
+	}
+}
@@ -0,0 +1,142 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"fmt"
+	"hash"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// rlockAll read-locks each distinct filter once, in lockedFirst order,
+// returning the matching unlock
+func rlockAll(filters []*Filter) (runlock func()) {
+	locked := slices.Clone(filters)
+	slices.SortFunc(locked, func(a, b *Filter) int {
+		switch {
+		case a == b:
+			return 0
+		case lockedFirst(a, b):
+			return -1
+		}
+		return 1
+	})
+	locked = slices.Compact(locked)
+	for _, f := range locked {
+		f.lock.RLock()
+	}
+	return func() {
+		for _, f := range locked {
+			f.lock.RUnlock()
+		}
+	}
+}
+
+// Merge unions all of filters into a new Filter out, with N() the sum of
+// theirs, saturating at math.MaxUint64 rather than wrapping around
+//
+// filters must all be compatible with each other, otherwise the error
+// names the first one that is not
+func Merge(filters ...*Filter) (out *Filter, err error) {
+	if len(filters) == 0 {
+		return nil, errNoFilters()
+	}
+
+	defer rlockAll(filters)()
+
+	for i, f := range filters[1:] {
+		if err := filters[0].verifyCompatible(f); err != nil {
+			return nil, fmt.Errorf("filters[%d]: %w", i+1, err)
+		}
+	}
+
+	out, err = filters[0].NewCompatible()
+	if err != nil {
+		return nil, err
+	}
+	n := uint64(0)
+	for _, f := range filters {
+		for i := range f.bits {
+			out.bits[i].Or(f.bits[i].Load())
+		}
+		n = saturatingAdd(n, f.n.Load())
+	}
+	out.n.Store(n)
+	return out, nil
+}
+
+// CommonCardinality estimates the size |A1∩A2∩...| of the intersection of
+// the sets held by all of filters, from the density of the AND of their
+// bits; for one filter, that is its EstimateN()
+//
+// Bits that every filter has set by coincidence, for different elements,
+// inflate the estimate, and more so the fuller the filters; each added
+// filter makes such coincidences rarer but compounds the error of the
+// others, so treat the result, clamped to the smallest EstimateN() of
+// filters, as a rough upper bound. filters must all be compatible with each
+// other, otherwise the error names the first one that is not
+func CommonCardinality(filters ...*Filter) (uint64, error) {
+	if len(filters) == 0 {
+		return 0, errNoFilters()
+	}
+
+	defer rlockAll(filters)()
+
+	for i, f := range filters[1:] {
+		if err := filters[0].verifyCompatible(f); err != nil {
+			return 0, fmt.Errorf("filters[%d]: %w", i+1, err)
+		}
+	}
+
+	first := filters[0]
+	smallest := uint64(math.MaxUint64)
+	for _, f := range filters {
+		smallest = min(smallest, f.estimateN(f.count()))
+	}
+	and := uint64(0)
+	for i := range first.bits {
+		word := first.bits[i].Load()
+		for _, f := range filters[1:] {
+			word &= f.bits[i].Load()
+		}
+		and += uint64(bits.OnesCount64(word))
+	}
+	return min(first.estimateN(and), smallest), nil
+}
+
+// AnyContains tests if any of filters contains v, stopping at the first
+// that maybe does, e.g. across filters of successive time windows
+// false: none of filters contains v, including when there are no filters
+// true:  at least one of filters maybe contains v
+func AnyContains(v hash.Hash64, filters ...*Filter) bool {
+	rawHash := v.Sum64()
+	for _, f := range filters {
+		if f.ContainsRaw(rawHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllContains tests if every one of filters contains v, stopping at the
+// first that definitely does not
+// false: at least one of filters definitely does not contain v
+// true:  all of filters maybe contain v, including when there are no filters
+func AllContains(v hash.Hash64, filters ...*Filter) bool {
+	rawHash := v.Sum64()
+	for _, f := range filters {
+		if !f.ContainsRaw(rawHash) {
+			return false
+		}
+	}
+	return true
+}
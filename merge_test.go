@@ -0,0 +1,149 @@
+package bloomfilter
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	first, _ := New(10000, 5)
+	shards := []*Filter{first}
+	for i := 1; i < 5; i++ {
+		shard, _ := first.NewCompatible()
+		shards = append(shards, shard)
+	}
+	for i := 0; i < 500; i++ {
+		shards[i%5].Add(hashableUint64(i))
+	}
+
+	out, err := Merge(shards...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sequential, _ := first.NewCompatible()
+	for _, shard := range shards {
+		if err := sequential.UnionInPlace(shard); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !out.Equal(sequential) {
+		t.Error("Merge differs from sequential UnionInPlace")
+	}
+	if out.N() != 500 {
+		t.Errorf("expected N()=500, got %d", out.N())
+	}
+}
+
+func TestMergeOverflow(t *testing.T) {
+	f, _ := New(10000, 5)
+	f2, _ := f.NewCompatible()
+	f.n.Store(math.MaxUint64 / 2)
+	f2.n.Store(math.MaxUint64 / 2)
+
+	out, err := Merge(f, f2, f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.N() != math.MaxUint64 {
+		t.Errorf("expected N() to saturate at %d, got %d", uint64(math.MaxUint64), out.N())
+	}
+}
+
+func TestMergeIncompatible(t *testing.T) {
+	f, _ := New(10000, 5)
+	f2, _ := f.NewCompatible()
+	f3, _ := New(10000, 5)
+
+	_, err := Merge(f, f2, f3)
+	if !errors.Is(err, ErrIncompatible) {
+		t.Errorf("expected %v, got %v", ErrIncompatible, err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "filters[2]") {
+		t.Errorf("expected the error to name filters[2], got %v", err)
+	}
+
+	if _, err := Merge(); err == nil {
+		t.Error("expected an error merging no filters")
+	}
+}
+
+func TestCommonCardinality(t *testing.T) {
+	first, _ := New(100000, 5)
+	filters := []*Filter{first}
+	for i := 1; i < 3; i++ {
+		f, _ := first.NewCompatible()
+		filters = append(filters, f)
+	}
+	// a core of 500 elements common to all three, plus 1000 of each's own,
+	// scattered by splitmix64 as hashableUint64 does not hash
+	item := func(j int) hashableUint64 {
+		_, out := splitmix64(uint64(j))
+		return hashableUint64(out)
+	}
+	for i, f := range filters {
+		for j := 0; j < 500; j++ {
+			f.Add(item(j))
+		}
+		for j := 0; j < 1000; j++ {
+			f.Add(item(1000000*(i+1) + j))
+		}
+	}
+
+	n, err := CommonCardinality(filters...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < 450 || n > 575 {
+		t.Errorf("expected about 500 common elements, got %d", n)
+	}
+
+	n, err = CommonCardinality(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != first.EstimateN() {
+		t.Errorf("expected EstimateN()=%d for one filter, got %d", first.EstimateN(), n)
+	}
+
+	if _, err := CommonCardinality(); err == nil {
+		t.Error("expected an error for no filters")
+	}
+	other, _ := New(100000, 5)
+	if _, err := CommonCardinality(first, other); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("expected ErrIncompatible, got %v", err)
+	}
+}
+
+func TestAnyAllContains(t *testing.T) {
+	filters := make([]*Filter, 3)
+	for i := range filters {
+		filters[i], _ = New(10000, 5)
+		filters[i].Add(hashableUint64(i))
+		filters[i].Add(hashableUint64(100))
+	}
+
+	for i := 0; i < 3; i++ {
+		if !AnyContains(hashableUint64(i), filters...) {
+			t.Errorf("AnyContains(%d) should be true", i)
+		}
+		if AllContains(hashableUint64(i), filters...) {
+			t.Errorf("AllContains(%d) should be false, as only filters[%d] has it", i, i)
+		}
+	}
+	if !AnyContains(hashableUint64(100), filters...) || !AllContains(hashableUint64(100), filters...) {
+		t.Error("AnyContains and AllContains should be true for an item in every filter")
+	}
+	if AnyContains(hashableUint64(200), filters...) || AllContains(hashableUint64(200), filters...) {
+		t.Error("AnyContains and AllContains should be false for an item in no filter")
+	}
+
+	if AnyContains(hashableUint64(100)) {
+		t.Error("AnyContains of no filters should be false")
+	}
+	if !AllContains(hashableUint64(100)) {
+		t.Error("AllContains of no filters should be true")
+	}
+}
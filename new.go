@@ -12,14 +12,19 @@ package bloomfilter
 import (
 	"crypto/rand"
 	"encoding/binary"
+	"io"
 	"log"
 	"sync/atomic"
 )
 
 const (
-	// MMin is the minimum Bloom filter bits count
+	// MMin is the minimum Bloom filter bits count, enforced by every
+	// constructor except NewUnchecked
 	MMin = 2
-	// KMin is the minimum number of keys
+	// MMax is the maximum Bloom filter bits count, enforced by every
+	// constructor, past which the bits could not be allocated
+	MMax = 1 << 48
+	// KMin is the minimum number of keys, enforced by every constructor
 	KMin = 1
 	// Uint64Bytes is the number of bytes in type uint64
 	Uint64Bytes = 8
@@ -27,32 +32,204 @@ const (
 
 // New Filter with CSPRNG keys
 //
-// m is the size of the Bloom filter, in bits, >= 2
+// m is the size of the Bloom filter, in bits, >= 2 and <= MMax
 //
 // k is the number of random keys, >= 1
 func New(m, k uint64) (*Filter, error) {
-	return NewWithKeys(m, newRandKeys(k))
+	if err := checkM(m); err != nil {
+		return nil, err
+	}
+	keys, err := newUniqueRandKeys(k)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithKeys(m, keys)
 }
 
-func newRandKeys(k uint64) []uint64 {
-	keys := make([]uint64, k)
-	err := binary.Read(rand.Reader, binary.LittleEndian, keys)
+// randKeysAttempts is how many times to draw k random keys before giving
+// up on them being unique, which even the first draw almost surely is
+const randKeysAttempts = 4
+
+// newUniqueRandKeys draws k distinct keys from crypto/rand
+func newUniqueRandKeys(k uint64) ([]uint64, error) {
+	return newUniqueReaderKeys(k, csprng{})
+}
+
+// csprng is crypto/rand.Reader, but panics rather than failing, as there
+// are no safe keys to be had without it
+type csprng struct{}
+
+func (csprng) Read(p []byte) (n int, err error) {
+	n, err = rand.Read(p)
 	if err != nil {
 		log.Panicf(
 			"Cannot read %d bytes from CSRPNG crypto/rand.Read (err=%v)",
-			Uint64Bytes, err,
+			len(p), err,
 		)
 	}
+	return n, nil
+}
+
+// newUniqueReaderKeys reads k keys from r, reading k more each time they
+// are not all distinct, up to randKeysAttempts times
+func newUniqueReaderKeys(k uint64, r io.Reader) ([]uint64, error) {
+	if k < KMin {
+		return nil, errK()
+	}
+	for i := 0; i < randKeysAttempts; i++ {
+		keys := make([]uint64, k)
+		err := binary.Read(r, binary.LittleEndian, keys)
+		if err != nil {
+			return nil, err
+		}
+		if UniqueKeys(keys) {
+			return keys, nil
+		}
+	}
+	return nil, errUniqueKeys()
+}
+
+// NewWithRand Filter with k keys read from r, like New but with the
+// randomness supplied, e.g. by a seeded math/rand.Rand for reproducible
+// keys in tests
+//
+// Keys are read as little-endian uint64s, reading another k whenever those
+// are not all distinct; an error reading r is returned as is. The keys are
+// only as unpredictable as r, so outside tests prefer New
+func NewWithRand(m, k uint64, r io.Reader) (*Filter, error) {
+	if err := checkM(m); err != nil {
+		return nil, err
+	}
+	keys, err := newUniqueReaderKeys(k, r)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithKeys(m, keys)
+}
+
+// NewWithSeed Filter with k keys derived deterministically from seed
+//
+// Filters built with the same m, k and seed have the same keys, so they are
+// compatible (see IsCompatible) without having to ship the keys around, and
+// their binary form holds just the seed in place of the keys. The
+// keys are only as unpredictable as seed; prefer New when the items can be
+// chosen by an adversary
+func NewWithSeed(m, k, seed uint64) (*Filter, error) {
+	if err := checkM(m); err != nil {
+		return nil, err
+	}
+	if k < KMin {
+		return nil, errK()
+	}
+	f, err := NewWithKeys(m, newSeededKeys(k, seed))
+	if err != nil {
+		return nil, err
+	}
+	f.seed, f.seeded = seed, true
+	return f, nil
+}
+
+// newSeededKeys draws k keys from a splitmix64 sequence starting at seed;
+// they are distinct, as the states are and the output mix is a bijection
+func newSeededKeys(k, seed uint64) []uint64 {
+	keys := make([]uint64, k)
+	for i := range keys {
+		seed, keys[i] = splitmix64(seed)
+	}
 	return keys
 }
 
-// NewCompatible Filter compatible with f
+// splitmix64 advances state and returns it with the next output
+func splitmix64(state uint64) (next, out uint64) {
+	next = state + 0x9e3779b97f4a7c15
+	z := next
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return next, z ^ (z >> 31)
+}
+
+// NewUnsafe Filter with CSPRNG keys, like New, for single-goroutine use
+//
+// Add, AddC and Contains (and the helpers built on them) of an unsafe filter
+// skip locking, which speeds up tight loops such as bulk builds. An unsafe
+// filter must not be used from more than one goroutine at a time, and
+// filters derived from it (NewCompatible, Copy, Union, ...) are not unsafe
+func NewUnsafe(m, k uint64) (*Filter, error) {
+	f, err := New(m, k)
+	if err != nil {
+		return nil, err
+	}
+	f.unlocked = true
+	return f, nil
+}
+
+// NewUnchecked Filter with CSPRNG keys, like New, but allowing m below MMin,
+// for deliberately tiny filters such as in tests
+//
+// Use with care: a filter that small holds almost nothing before every
+// lookup is a false positive, and it cannot be the basis of NewCompatible,
+// nor so of Copy, Union and the like, which enforce MMin. m must still be at
+// least 1 and k at least KMin, as no filter works without a bit or a key,
+// and m at most MMax
+func NewUnchecked(m, k uint64) (*Filter, error) {
+	if m == 0 {
+		return nil, errMZero()
+	}
+	if m > MMax {
+		return nil, errMMax()
+	}
+	keys, err := newUniqueRandKeys(k)
+	if err != nil {
+		return nil, err
+	}
+	out := &Filter{
+		m:    m,
+		bits: make([]atomic.Uint64, (m+63)/64),
+		keys: keys,
+	}
+	return out, nil
+}
+
+// NewCompatible empty Filter compatible with f: with the same m, keys and
+// PositionFunc, so IsCompatible(f), but with no bits set and N() of 0, for
+// preallocating the target of a union or a shard
+//
+// out always locks, even when f is from NewUnsafe
 func (f *Filter) NewCompatible() (*Filter, error) {
-	return NewWithKeys(f.m, f.keys)
+	out, err := NewWithKeys(f.m, f.keys)
+	if err != nil {
+		return nil, err
+	}
+	out.positions = f.positions
+	out.seed, out.seeded = f.seed, f.seeded
+	return out, nil
+}
+
+// NewWithPositionFunc Filter with CSPRNG keys, like New, deriving bit
+// positions with positions instead of the default key-XOR scheme, e.g. to
+// interoperate with filters built by other libraries
+//
+// positions is not part of any serialized form, nor can IsCompatible check
+// it: only combine such a filter with filters using the same PositionFunc,
+// and to deserialize one, unmarshal into a filter that already has it
+func NewWithPositionFunc(m, k uint64, positions PositionFunc) (*Filter, error) {
+	f, err := New(m, k)
+	if err != nil {
+		return nil, err
+	}
+	f.positions = positions
+	return f, nil
 }
 
 // NewOptimal Bloom filter with random CSPRNG keys
+//
+// maxN is the maximum anticipated number of elements
+//
+// p is the desired false positive probability at maxN, 0 < p < 1
 func NewOptimal(maxN uint64, p float64) (*Filter, error) {
+	if !(p > 0 && p < 1) {
+		return nil, errP()
+	}
 	m := OptimalM(maxN, p)
 	k := OptimalK(m, maxN)
 	debug("New optimal bloom filter ::"+
@@ -66,9 +243,9 @@ func NewOptimal(maxN uint64, p float64) (*Filter, error) {
 
 // UniqueKeys is true if all keys are unique
 func UniqueKeys(keys []uint64) bool {
-	for j := 0; j < len(keys)-1; j++ {
+	for j := 1; j < len(keys); j++ {
 		elem := keys[j]
-		for i := 1; i < j; i++ {
+		for i := 0; i < j; i++ {
 			if keys[i] == elem {
 				return false
 			}
@@ -78,6 +255,11 @@ func UniqueKeys(keys []uint64) bool {
 }
 
 // NewWithKeys creates a new Filter from user-supplied origKeys
+//
+// m is the size of the Bloom filter, in bits, >= 2 and <= MMax
+//
+// origKeys must be unique and there must be at least 1 of them; filters
+// built with the same m and origKeys are compatible
 func NewWithKeys(m uint64, origKeys []uint64) (f *Filter, err error) {
 	bits, err := newBits(m)
 	if err != nil {
@@ -96,9 +278,36 @@ func NewWithKeys(m uint64, origKeys []uint64) (f *Filter, err error) {
 	return &out, nil
 }
 
+// NewFromBitSet creates a new Filter from user-supplied keys and bits, as
+// returned by Keys and BitSet, with N() of 0
+//
+// bits must hold exactly (m+63)/64 words
+func NewFromBitSet(m uint64, keys []uint64, bits []uint64) (*Filter, error) {
+	f, err := NewWithKeys(m, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(bits) != len(f.bits) {
+		return nil, errBitSet(len(f.bits), len(bits))
+	}
+	f.setBits(bits)
+	return f, nil
+}
+
+// checkM checks m is within MMin and MMax
+func checkM(m uint64) error {
+	switch {
+	case m < MMin:
+		return errM()
+	case m > MMax:
+		return errMMax()
+	}
+	return nil
+}
+
 func newBits(m uint64) ([]atomic.Uint64, error) {
-	if m < MMin {
-		return nil, errM()
+	if err := checkM(m); err != nil {
+		return nil, err
 	}
 	return make([]atomic.Uint64, (m+63)/64), nil
 }
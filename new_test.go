@@ -0,0 +1,381 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestNewOptimal(t *testing.T) {
+	tests := []struct {
+		maxN uint64
+		p    float64
+	}{
+		{maxN: 1000, p: 0.01},
+		{maxN: 1000, p: 0.01 / 100},
+		{maxN: 100000, p: 0.001},
+	}
+
+	for _, test := range tests {
+		f, err := NewOptimal(test.maxN, test.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.n.Store(test.maxN)
+
+		p := f.FalsePositiveProbability()
+		if math.Abs(p-test.p) > test.p*0.1 {
+			t.Errorf("maxN=%d p=%g: got p=%g at maxN (m=%d, k=%d)",
+				test.maxN, test.p, p, f.M(), f.K())
+		}
+	}
+}
+
+func TestNewOptimalInvalidP(t *testing.T) {
+	for _, p := range []float64{0, 1, -0.5, 1.5, math.NaN()} {
+		if _, err := NewOptimal(1000, p); err == nil {
+			t.Errorf("p=%g: expected an error", p)
+		}
+	}
+}
+
+func TestNewWithKeys(t *testing.T) {
+	f, _ := New(10000, 5)
+
+	keys := f.Keys()
+	keys[0]++
+	if f.keys[0] == keys[0] {
+		t.Error("Keys() should return a copy")
+	}
+
+	f2, err := NewWithKeys(f.M(), f.Keys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsCompatible(f2) {
+		t.Error("NewWithKeys(f.M(), f.Keys()) should be compatible with f")
+	}
+}
+
+func TestNewWithKeysInvalid(t *testing.T) {
+	tests := []struct {
+		m    uint64
+		keys []uint64
+		err  error
+	}{
+		{m: 1, keys: []uint64{1, 2}, err: errM()},
+		{m: 100, keys: []uint64{}, err: errK()},
+		{m: 100, keys: []uint64{1, 1}, err: errUniqueKeys()},
+		{m: 100, keys: []uint64{1, 2, 3, 1}, err: errUniqueKeys()},
+		{m: 100, keys: []uint64{1, 2, 3, 3}, err: errUniqueKeys()},
+	}
+
+	for _, test := range tests {
+		_, err := NewWithKeys(test.m, test.keys)
+		if err == nil || err.Error() != test.err.Error() {
+			t.Errorf("m=%d keys=%v: expected %v, got %v",
+				test.m, test.keys, test.err, err)
+		}
+	}
+}
+
+// kirschMitzenmacher derives positions as h1 + i*h2 from the two halves of
+// the raw hash
+func kirschMitzenmacher(rawHash uint64, k int) []uint64 {
+	h1, h2 := rawHash&0xffffffff, rawHash>>32
+	positions := make([]uint64, k)
+	for i := range positions {
+		positions[i] = h1 + uint64(i)*h2
+	}
+	return positions
+}
+
+func TestNewWithPositionFunc(t *testing.T) {
+	f, err := NewWithPositionFunc(10000, 5, kirschMitzenmacher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+	for _, x := range hashableUint64Values() {
+		if !f.Contains(x) || !f.AddC(x) {
+			t.Error("definitely does not contain ", x, ", but it should")
+		}
+	}
+
+	// only the position function decides which bits are set
+	v := hashableUint64(0x0000000300000005)
+	f2, _ := NewWithPositionFunc(10000, 5, kirschMitzenmacher)
+	f2.Add(v)
+	for _, i := range []uint64{5, 8, 11, 14, 17} {
+		if f2.bits[i>>6].Load()&(1<<(i&0x3f)) == 0 {
+			t.Errorf("expected bit %d to be set", i)
+		}
+	}
+	if f2.Count() != 5 {
+		t.Errorf("expected 5 bits set, got %d", f2.Count())
+	}
+
+	data, _ := f.MarshalBinary()
+	f3, _ := NewWithPositionFunc(2, 1, kirschMitzenmacher)
+	if err := f3.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range hashableUint64Values() {
+		if !f3.Contains(x) {
+			t.Error("unmarshalled filter definitely does not contain ", x)
+		}
+	}
+	if f4, _ := f.NewCompatible(); f4.positions == nil {
+		t.Error("NewCompatible() should keep the PositionFunc")
+	}
+}
+
+func TestNewFromBitSet(t *testing.T) {
+	f, _ := New(1000, 5)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+
+	bits := f.BitSet()
+	f2, err := NewFromBitSet(f.M(), f.Keys(), bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f2.Equal(f) {
+		t.Error("NewFromBitSet(f.M(), f.Keys(), f.BitSet()) should equal f")
+	}
+
+	bits[0] ^= 1
+	if !f2.Equal(f) {
+		t.Error("BitSet() and NewFromBitSet should copy the bits")
+	}
+
+	if _, err := NewFromBitSet(f.M(), f.Keys(), bits[1:]); err == nil {
+		t.Error("expected an error for too few words")
+	}
+	if _, err := NewFromBitSet(f.M(), []uint64{1, 1}, bits); err == nil {
+		t.Error("expected an error for duplicate keys")
+	}
+}
+
+func TestNew(t *testing.T) {
+	f, err := New(100, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.M() != 100 || f.K() != 3 || f.N() != 0 || len(f.bits) != 2 {
+		t.Errorf("expected (m=100, k=3, n=0, 2 words), got (m=%d, k=%d, n=%d, %d words)",
+			f.M(), f.K(), f.N(), len(f.bits))
+	}
+	if !UniqueKeys(f.keys) {
+		t.Error("New should generate unique keys")
+	}
+	if !f.IsEmpty() {
+		t.Error("New should have no bits set")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		m, k uint64
+		err  error
+	}{
+		{m: 0, k: 3, err: errM()},
+		{m: MMin - 1, k: 3, err: errM()},
+		{m: 100, k: 0, err: errK()},
+		{m: 0, k: 0, err: errM()},
+		{m: MMax + 1, k: 3, err: errMMax()},
+		{m: math.MaxUint64, k: 3, err: errMMax()},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.m, test.k)
+		if err == nil || err.Error() != test.err.Error() {
+			t.Errorf("m=%d k=%d: expected %v, got %v", test.m, test.k, test.err, err)
+		}
+	}
+
+	keys := []uint64{1, 2, 3}
+	for name, build := range map[string]func() (any, error){
+		"NewWithKeys":   func() (any, error) { return NewWithKeys(math.MaxUint64-1, keys) },
+		"NewWithSeed":   func() (any, error) { return NewWithSeed(math.MaxUint64-10, 3, 1) },
+		"NewFromBitSet": func() (any, error) { return NewFromBitSet(math.MaxUint64, keys, nil) },
+		"NewUnchecked":  func() (any, error) { return NewUnchecked(math.MaxUint64, 3) },
+		"NewBlocked":    func() (any, error) { return NewBlocked(math.MaxUint64, 3) },
+		"NewCounting":   func() (any, error) { return NewCounting(math.MaxUint64, 3) },
+	} {
+		if _, err := build(); !errors.Is(err, errMMax()) {
+			t.Errorf("%s: expected %v, got %v", name, errMMax(), err)
+		}
+	}
+
+	f, _ := New(3, 3)
+	if _, err := f.Resize(math.MaxUint64); !errors.Is(err, errMMax()) {
+		t.Errorf("Resize: expected %v, got %v", errMMax(), err)
+	}
+}
+
+func TestNewWithSeed(t *testing.T) {
+	f, err := NewWithSeed(1000, 4, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := NewWithSeed(1000, 4, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !UniqueKeys(f.keys) {
+		t.Errorf("expected unique keys, got %v", f.keys)
+	}
+	if !f.IsCompatible(f2) {
+		t.Fatal("filters with the same m, k and seed should be compatible")
+	}
+
+	f.Add(hashableUint64(1))
+	f2.Add(hashableUint64(2))
+	if err := f.UnionInPlace(f2); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Contains(hashableUint64(1)) || !f.Contains(hashableUint64(2)) {
+		t.Error("union should contain items from both filters")
+	}
+
+	f3, err := NewWithSeed(1000, 4, 43)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.IsCompatible(f3) {
+		t.Error("filters with different seeds should not be compatible")
+	}
+}
+
+func TestNewWithSeedInvalid(t *testing.T) {
+	if _, err := NewWithSeed(1, 4, 42); err == nil {
+		t.Error("expected an error for m < MMin")
+	}
+	if _, err := NewWithSeed(1000, 0, 42); err == nil {
+		t.Error("expected an error for k < KMin")
+	}
+}
+
+func TestNewWithRand(t *testing.T) {
+	f, err := NewWithRand(1000, 4, rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := NewWithRand(1000, 4, rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !UniqueKeys(f.keys) {
+		t.Errorf("expected unique keys, got %v", f.keys)
+	}
+	if !f.IsCompatible(f2) || !slices.Equal(f.Keys(), f2.Keys()) {
+		t.Error("filters from the same random source should have the same keys")
+	}
+
+	f3, _ := NewWithRand(1000, 4, rand.New(rand.NewSource(43)))
+	if f.IsCompatible(f3) {
+		t.Error("filters from different random sources should not be compatible")
+	}
+
+	// a key repeated within the first draw is retried with the next
+	var b []byte
+	for _, key := range []uint64{1, 1, 2, 3} {
+		b = binary.LittleEndian.AppendUint64(b, key)
+	}
+	f4, err := NewWithRand(1000, 2, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(f4.Keys(), []uint64{2, 3}) {
+		t.Errorf("expected keys [2 3] after a collision, got %v", f4.Keys())
+	}
+}
+
+func TestNewWithRandInvalid(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	if _, err := NewWithRand(1, 4, r); err == nil {
+		t.Error("expected an error for m < MMin")
+	}
+	if _, err := NewWithRand(1000, 0, r); err == nil {
+		t.Error("expected an error for k < KMin")
+	}
+	if _, err := NewWithRand(1000, 2, bytes.NewReader(make([]byte, 1024))); err == nil ||
+		err.Error() != errUniqueKeys().Error() {
+		t.Errorf("expected %v for a source of only zeros, got %v", errUniqueKeys(), err)
+	}
+	if _, err := NewWithRand(1000, 4, bytes.NewReader(make([]byte, 8))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF for a short source, got %v", err)
+	}
+}
+
+func TestNewUnchecked(t *testing.T) {
+	f, err := NewUnchecked(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.M() != 1 || f.K() != 2 || len(f.bits) != 1 {
+		t.Errorf("expected (m=1, k=2, 1 word), got (m=%d, k=%d, %d words)", f.M(), f.K(), len(f.bits))
+	}
+	if f.Contains(hashableUint64(1)) {
+		t.Error("an empty filter should not contain anything")
+	}
+	f.Add(hashableUint64(1))
+	if !f.Contains(hashableUint64(1)) || !f.Contains(hashableUint64(2)) {
+		t.Error("a full 1-bit filter should contain everything")
+	}
+	if f.Count() != 1 || f.FalsePositiveProbability() != 1 {
+		t.Errorf("expected count=1 and p=1, got count=%d and p=%g", f.Count(), f.FalsePositiveProbability())
+	}
+
+	if _, err := NewUnchecked(0, 2); err == nil {
+		t.Error("expected an error for m=0")
+	}
+	if _, err := NewUnchecked(1, 0); err == nil {
+		t.Error("expected an error for k=0")
+	}
+}
+
+func TestNewCompatible(t *testing.T) {
+	f, _ := New(1000, 5)
+	for i := 0; i < 100; i++ {
+		f.Add(hashableUint64(i))
+	}
+
+	out, err := f.NewCompatible()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsCompatible(out) || !out.IsCompatible(f) {
+		t.Error("NewCompatible should be compatible with its source")
+	}
+	if out.M() != f.M() || out.K() != f.K() {
+		t.Errorf("expected (m=%d, k=%d), got (m=%d, k=%d)", f.M(), f.K(), out.M(), out.K())
+	}
+	if out.N() != 0 || !out.IsEmpty() {
+		t.Errorf("expected an empty filter, got n=%d and %d set bits", out.N(), out.Count())
+	}
+	for i := 0; i < 100; i++ {
+		if out.Contains(hashableUint64(i)) {
+			t.Errorf("item %d should not carry over", i)
+		}
+	}
+
+	out.keys[0]++
+	if !f.IsCompatible(f) || f.IsCompatible(out) {
+		t.Error("NewCompatible should copy the keys, not share them")
+	}
+
+	unsafe, _ := NewUnsafe(1000, 5)
+	if out, _ := unsafe.NewCompatible(); out.unlocked {
+		t.Error("NewCompatible of an unsafe filter should not be unsafe")
+	}
+}
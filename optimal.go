@@ -14,15 +14,40 @@ import "math"
 
 const gigabitsPerGiB float64 = 8.0 * 1024 * 1024 * 1024
 
+// clampUint64 converts x to uint64, rounding up, and keeping it between lo
+// and math.MaxUint64 (NaN becomes lo)
+func clampUint64(x float64, lo uint64) uint64 {
+	x = math.Ceil(x)
+	switch {
+	case !(x >= float64(lo)):
+		return lo
+	case x >= math.MaxUint64:
+		return math.MaxUint64
+	}
+	return uint64(x)
+}
+
 // OptimalK calculates the optimal k value for creating a new Bloom filter
-// maxn is the maximum anticipated number of elements
+// maxn is the maximum anticipated number of elements (0 is treated as 1)
+// optimal k = ceiling( m * ln(2) / n ), and at least KMin
 func OptimalK(m, maxN uint64) uint64 {
-	return uint64(math.Ceil(float64(m) * math.Ln2 / float64(maxN)))
+	maxN = max(maxN, 1)
+	return clampUint64(float64(m)*math.Ln2/float64(maxN), KMin)
 }
 
 // OptimalM calculates the optimal m value for creating a new Bloom filter
-// p is the desired false positive probability
-// optimal m = ceiling( - n * ln(p) / ln(2)**2 )
+// p is the desired false positive probability, 0 < p < 1
+// optimal m = ceiling( - n * ln(p) / ln(2)**2 ), and at least MMin
 func OptimalM(maxN uint64, p float64) uint64 {
-	return uint64(math.Ceil(-float64(maxN) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	return clampUint64(-float64(maxN)*math.Log(p)/(math.Ln2*math.Ln2), MMin)
+}
+
+// OptimalKForCurrentLoad calculates the k that would be optimal for f's m at
+// its current N(), say after unions have carried it away from the n it was
+// sized for; k of a live filter cannot change, but this tells whether
+// rebuilding it is worthwhile
+// optimal k = round( m * ln(2) / n ), and at least KMin (0 is treated as 1)
+func (f *Filter) OptimalKForCurrentLoad() uint64 {
+	n := max(f.N(), 1)
+	return clampUint64(math.Round(float64(f.m)*math.Ln2/float64(n)), KMin)
 }
@@ -1,6 +1,7 @@
 package bloomfilter
 
 import (
+	"math"
 	"testing"
 )
 
@@ -34,6 +35,12 @@ func TestOptimal(t *testing.T) {
 			k: 17,
 			m: 23963,
 		},
+		{
+			n: 1000 * 1000,
+			p: 0.01,
+			k: 7,
+			m: 9585059,
+		},
 	}
 
 	for _, test := range tests {
@@ -53,3 +60,43 @@ func TestOptimal(t *testing.T) {
 		}
 	}
 }
+
+func TestOptimalClamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		got, exp uint64
+	}{
+		{"OptimalM(0, 0.01)", OptimalM(0, 0.01), MMin},
+		{"OptimalM(1000, 1)", OptimalM(1000, 1), MMin},
+		{"OptimalM(1000, 0)", OptimalM(1000, 0), math.MaxUint64},
+		{"OptimalK(10, 1000)", OptimalK(10, 1000), KMin},
+		{"OptimalK(0, 1000)", OptimalK(0, 1000), KMin},
+		{"OptimalK(1000, 0)", OptimalK(1000, 0), OptimalK(1000, 1)},
+	}
+
+	for _, test := range tests {
+		if test.got != test.exp {
+			t.Errorf("%s: expected %d, got %d", test.name, test.exp, test.got)
+		}
+	}
+}
+
+func TestOptimalKForCurrentLoad(t *testing.T) {
+	f, _ := New(10000, 7)
+	for _, n := range []uint64{1, 100, 1000, 1443, 5000, 100000} {
+		f.SetN(n)
+		exp := uint64(math.Round(float64(f.M()) / float64(n) * math.Ln2))
+		exp = max(exp, KMin)
+		if k := f.OptimalKForCurrentLoad(); k != exp {
+			t.Errorf("n=%d: expected k=%d, got %d", n, exp, k)
+		}
+	}
+
+	f.SetN(0)
+	if k, exp := f.OptimalKForCurrentLoad(), uint64(6931); k != exp {
+		t.Errorf("n=0: expected k=%d, got %d", exp, k)
+	}
+	if f.K() != 7 {
+		t.Error("OptimalKForCurrentLoad should not change K()")
+	}
+}
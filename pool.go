@@ -0,0 +1,61 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import "sync"
+
+// poolKey is the size of the filters in a pool
+type poolKey struct {
+	m, k uint64
+}
+
+// pools holds a *sync.Pool of filters for each poolKey
+var pools sync.Map
+
+func poolFor(m, k uint64) *sync.Pool {
+	if p, ok := pools.Load(poolKey{m, k}); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := pools.LoadOrStore(poolKey{m, k}, new(sync.Pool))
+	return p.(*sync.Pool)
+}
+
+// GetPooled an empty Filter of m bits and k keys, like New, reusing one
+// given back by PutPooled when available, which saves allocating the bits of
+// short-lived filters over and over
+//
+// A reused filter keeps the CSPRNG keys it was made with, so two filters
+// from GetPooled are not compatible, nor guaranteed to differ in keys
+func GetPooled(m, k uint64) (*Filter, error) {
+	if f, ok := poolFor(m, k).Get().(*Filter); ok {
+		return f, nil
+	}
+	f, err := New(m, k)
+	if err != nil {
+		return nil, err
+	}
+	f.pooled = true
+	return f, nil
+}
+
+// PutPooled gives f, from GetPooled, back for reuse, clearing it and
+// removing its rotation threshold first; f must not be used afterwards
+//
+// A filter not from GetPooled is left to the garbage collector instead, as
+// its keys may be known or its settings, such as NewUnsafe or a
+// PositionFunc, would carry over to the next GetPooled
+func PutPooled(f *Filter) {
+	if !f.pooled {
+		return
+	}
+	f.Clear()
+	f.SetRotationThreshold(0, nil)
+	poolFor(f.M(), f.K()).Put(f)
+}
@@ -0,0 +1,95 @@
+package bloomfilter
+
+import "testing"
+
+func TestPooled(t *testing.T) {
+	f, err := GetPooled(10000, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		f.Add(hashableUint64(i))
+	}
+	PutPooled(f)
+
+	for j := 0; j < 10; j++ {
+		f, err = GetPooled(10000, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.M() != 10000 || f.K() != 5 {
+			t.Errorf("expected (m=10000, k=5), got (m=%d, k=%d)", f.M(), f.K())
+		}
+		if f.N() != 0 || !f.IsEmpty() {
+			t.Errorf("expected an empty filter, got n=%d", f.N())
+		}
+		for i := 0; i < 100; i++ {
+			if f.Contains(hashableUint64(i)) {
+				t.Errorf("a pooled filter should not contain prior item %d", i)
+			}
+		}
+		f.Add(hashableUint64(j))
+		PutPooled(f)
+	}
+
+	if _, err := GetPooled(1, 5); err == nil {
+		t.Error("expected an error for m < MMin")
+	}
+}
+
+func TestPutPooledResets(t *testing.T) {
+	f, _ := GetPooled(20000, 6)
+	f.SetRotationThreshold(0.01, func(*Filter) {
+		t.Error("a rotation threshold should not carry over to the next GetPooled")
+	})
+	PutPooled(f)
+
+	for j := 0; j < 10; j++ {
+		f, _ = GetPooled(20000, 6)
+		if f.rotation.Load() != nil {
+			t.Fatal("expected no rotation threshold on a pooled filter")
+		}
+		for i := 0; i < 3000; i++ {
+			f.Add(hashableUint64(i))
+		}
+		PutPooled(f)
+	}
+
+	keys := make([]uint64, 6)
+	for i := range keys {
+		keys[i] = uint64(i + 1)
+	}
+	for _, foreign := range []func() (*Filter, error){
+		func() (*Filter, error) { return NewWithKeys(20000, keys) },
+		func() (*Filter, error) { return NewUnsafe(20000, 6) },
+		func() (*Filter, error) { return NewWithSeed(20000, 6, 42) },
+	} {
+		f, err := foreign()
+		if err != nil {
+			t.Fatal(err)
+		}
+		PutPooled(f)
+		for j := 0; j < 10; j++ {
+			if got, _ := GetPooled(20000, 6); got == f {
+				t.Fatal("PutPooled should not reuse a filter not from GetPooled")
+			}
+		}
+	}
+}
+
+func BenchmarkNew1MX5(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f, _ := New(1_000_000, 5)
+		f.Add(hashableUint64(i))
+	}
+}
+
+func BenchmarkGetPooled1MX5(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f, _ := GetPooled(1_000_000, 5)
+		f.Add(hashableUint64(i))
+		PutPooled(f)
+	}
+}
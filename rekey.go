@@ -0,0 +1,53 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import "hash"
+
+// RekeyFrom rebuilds src in f, which has its own, typically fresh, keys, by
+// adding items to f, for rotating keys away from adversarially chosen items
+//
+// Bits cannot be moved between key sets, so items must be the elements of
+// src, kept elsewhere; each is checked to be maybe in src before any is
+// added, to catch passing the wrong items. f must be empty so as not to mix
+// bits of two key sets, and f and src need not be compatible, indeed they
+// usually are not
+func (f *Filter) RekeyFrom(src *Filter, items []hash.Hash64) error {
+	if f == src {
+		return errRekeySelf()
+	}
+	if lockedFirst(src, f) {
+		src.lock.RLock()
+		f.lock.Lock()
+	} else {
+		f.lock.Lock()
+		src.lock.RLock()
+	}
+	defer src.lock.RUnlock()
+	defer f.lock.Unlock()
+	f.unshareLocked()
+
+	if f.n.Load() != 0 || f.count() != 0 {
+		return errNotEmpty()
+	}
+	rawHashes := make([]uint64, len(items))
+	for i, v := range items {
+		rawHashes[i] = v.Sum64()
+		if !src.contains(rawHashes[i]) {
+			return errRekeyMissing(i)
+		}
+	}
+
+	for _, rawHash := range rawHashes {
+		f.add(rawHash)
+	}
+	f.n.Store(uint64(len(items)))
+	return nil
+}
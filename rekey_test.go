@@ -0,0 +1,53 @@
+package bloomfilter
+
+import (
+	"hash"
+	"testing"
+)
+
+func TestRekeyFrom(t *testing.T) {
+	items := make([]hash.Hash64, 100)
+	for i := range items {
+		items[i] = hashableUint64(i)
+	}
+	src, _ := New(10000, 5)
+	src.AddAll(items)
+
+	f, _ := New(10000, 5)
+	if err := f.RekeyFrom(src, items); err != nil {
+		t.Fatal(err)
+	}
+	if src.IsCompatible(f) {
+		t.Error("filters with different keys should not be compatible")
+	}
+	if f.N() != src.N() {
+		t.Errorf("expected n=%d, got %d", src.N(), f.N())
+	}
+	for _, v := range items {
+		if !src.Contains(v) || !f.Contains(v) {
+			t.Errorf("both filters should contain %d", v)
+		}
+	}
+}
+
+func TestRekeyFromInvalid(t *testing.T) {
+	src, _ := New(10000, 5)
+	src.Add(hashableUint64(1))
+
+	f, _ := New(10000, 5)
+	if err := f.RekeyFrom(src, []hash.Hash64{hashableUint64(1), hashableUint64(2)}); err == nil {
+		t.Error("expected an error for an item not in src")
+	}
+	if !f.IsEmpty() {
+		t.Error("no item should be added when any is not in src")
+	}
+
+	f.Add(hashableUint64(3))
+	if err := f.RekeyFrom(src, []hash.Hash64{hashableUint64(1)}); err == nil {
+		t.Error("expected an error for a filter that is not empty")
+	}
+
+	if err := src.RekeyFrom(src, nil); err == nil {
+		t.Error("expected an error for rekeying from itself")
+	}
+}
@@ -0,0 +1,57 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import "sync/atomic"
+
+// rotationCheckEvery is how many adds apart the rotation threshold is
+// checked, a power of 2 to keep the test for it cheap
+const rotationCheckEvery = 64
+
+// rotation is a threshold set by SetRotationThreshold
+type rotation struct {
+	p     float64
+	cb    func(*Filter)
+	fired atomic.Bool
+}
+
+// SetRotationThreshold arranges for cb(f) to be called once, when
+// FalsePositiveProbability() first exceeds p, to rotate f out before it
+// fills up
+//
+// The threshold is checked every 64 elements added by Add, AddC, AddNew,
+// TestAndAdd, AddAll and the helpers built on them, so cb may run up to 63
+// elements late; elements added by an Adder are only counted, and so
+// checked, when it flushes. cb runs on the goroutine of the add
+// that crossed the threshold, with no lock held, so it may use f freely.
+// Calling SetRotationThreshold again replaces the threshold and re-arms it;
+// a nil cb removes it
+func (f *Filter) SetRotationThreshold(p float64, cb func(*Filter)) {
+	if cb == nil {
+		f.rotation.Store(nil)
+		return
+	}
+	f.rotation.Store(&rotation{p: p, cb: cb})
+}
+
+// checkRotation fires the rotation threshold, if set and crossed, after an
+// add of delta elements brought N() to n; the caller must not hold f.lock
+func (f *Filter) checkRotation(n, delta uint64) {
+	if (n-delta)/rotationCheckEvery == n/rotationCheckEvery {
+		return
+	}
+	r := f.rotation.Load()
+	if r == nil || f.FalsePositiveProbabilityAt(n) <= r.p {
+		return
+	}
+	if r.fired.CompareAndSwap(false, true) {
+		r.cb(f)
+	}
+}
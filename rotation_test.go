@@ -0,0 +1,108 @@
+package bloomfilter
+
+import (
+	"hash"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSetRotationThreshold(t *testing.T) {
+	const p = 0.01
+	f, _ := NewOptimal(1000, p)
+
+	var calls atomic.Int32
+	var firedAt uint64
+	f.SetRotationThreshold(p, func(got *Filter) {
+		if got != f {
+			t.Error("the callback should be passed the filter")
+		}
+		calls.Add(1)
+		firedAt = got.N()
+		// no lock is held
+		if _, err := got.Copy(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	for i := 0; i < 3000; i++ {
+		f.Add(hashableUint64(i))
+		if i%2 == 0 {
+			f.AddC(hashableUint64(i + 1000000))
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected the callback to run exactly once, got %d", calls.Load())
+	}
+	if f.FalsePositiveProbabilityAt(firedAt) <= p ||
+		f.FalsePositiveProbabilityAt(firedAt-rotationCheckEvery) > p {
+		t.Errorf("expected the callback within %d elements of the threshold, got n=%d",
+			rotationCheckEvery, firedAt)
+	}
+}
+
+func TestSetRotationThresholdConcurrent(t *testing.T) {
+	f, _ := NewOptimal(1000, 0.01)
+	var calls atomic.Int32
+	f.SetRotationThreshold(0.01, func(*Filter) { calls.Add(1) })
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			items := make([]hash.Hash64, 100)
+			for j := 0; j < 5; j++ {
+				for i := range items {
+					items[i] = hashableUint64(g*1000 + j*100 + i)
+				}
+				f.AddAll(items)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("expected the callback to run exactly once, got %d", calls.Load())
+	}
+
+	f.SetRotationThreshold(0.01, nil)
+	f.SetRotationThreshold(0.99, func(*Filter) { calls.Add(1) })
+	for i := 0; i < 100; i++ {
+		f.Add(hashableUint64(i))
+	}
+	if calls.Load() != 1 {
+		t.Errorf("a threshold not crossed should not fire, got %d calls", calls.Load())
+	}
+}
+
+func TestSetRotationThresholdAdder(t *testing.T) {
+	f, _ := NewOptimal(1000, 0.01)
+	var calls atomic.Int32
+	f.SetRotationThreshold(0.01, func(*Filter) { calls.Add(1) })
+
+	a := f.NewAdder()
+	for i := 0; i < 3000; i++ {
+		a.Add(hashableUint64(i))
+	}
+	a.Flush()
+	if calls.Load() != 1 {
+		t.Errorf("expected the callback to run exactly once, got %d", calls.Load())
+	}
+}
+
+func TestSetRotationThresholdAddNew(t *testing.T) {
+	for name, add := range map[string]func(*Filter, hash.Hash64) bool{
+		"AddNew":     (*Filter).AddNew,
+		"TestAndAdd": (*Filter).TestAndAdd,
+	} {
+		f, _ := NewOptimal(1000, 0.01)
+		var calls atomic.Int32
+		f.SetRotationThreshold(0.01, func(*Filter) { calls.Add(1) })
+		for i := 0; i < 3000; i++ {
+			add(f, hashableUint64(i))
+		}
+		if calls.Load() != 1 {
+			t.Errorf("%s: expected the callback to run exactly once, got %d", name, calls.Load())
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"hash"
+	"sync"
+)
+
+const (
+	// scalableGrowth is how many times larger each stage is than the last
+	scalableGrowth = 2
+	// scalableTightening is how many times smaller the false positive
+	// probability of each stage is than that of the last
+	scalableTightening = 0.85
+)
+
+// ScalableFilter is a Bloom filter that grows as it fills, keeping its false
+// positive probability under a target no matter how many elements are added
+// (Almeida et al., "Scalable Bloom Filters", 2007)
+//
+// It is a series of stages, each a Filter; elements are added to the latest
+// stage, and once that holds as many elements as it was sized for, a new
+// stage twice as large, and with a tighter false positive probability, is
+// started. Contains checks every stage.
+type ScalableFilter struct {
+	lock       sync.RWMutex // RLock to add to the latest stage, Lock to start one
+	stages     []*Filter
+	capacities []uint64 // maximum number of elements of each stage
+	p          float64  // false positive probability of the latest stage
+}
+
+// NewScalable ScalableFilter with random CSPRNG keys
+//
+// initialN is the number of elements the first stage is sized for
+//
+// p is the false positive probability the whole filter stays under, 0 < p < 1
+func NewScalable(initialN uint64, p float64) (*ScalableFilter, error) {
+	if !(p > 0 && p < 1) {
+		return nil, errP()
+	}
+	s := &ScalableFilter{p: p * (1 - scalableTightening)}
+	err := s.addStage(max(initialN, 1))
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// addStage starts a new stage for maxN elements; the caller must hold s.lock
+func (s *ScalableFilter) addStage(maxN uint64) error {
+	f, err := NewOptimal(maxN, s.p)
+	if err != nil {
+		return err
+	}
+	s.stages = append(s.stages, f)
+	s.capacities = append(s.capacities, maxN)
+	s.p *= scalableTightening
+	return nil
+}
+
+// Add a hashable item, v, to the filter
+func (s *ScalableFilter) Add(v hash.Hash64) {
+	s.lock.RLock()
+	last := len(s.stages) - 1
+	stage := s.stages[last]
+	stage.Add(v)
+	full := stage.N() >= s.capacities[last]
+	s.lock.RUnlock()
+
+	if full {
+		s.grow(stage)
+	}
+}
+
+// grow starts a new stage after full, unless another Add already has
+func (s *ScalableFilter) grow(full *Filter) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	last := len(s.stages) - 1
+	if s.stages[last] != full {
+		return
+	}
+	// the sizes are valid whenever the first stage's were, so this cannot
+	// fail short of CSPRNG failure, which panics
+	_ = s.addStage(s.capacities[last] * scalableGrowth)
+}
+
+// Contains tests if s contains v
+// false: s definitely does not contain value v
+// true:  s maybe contains value v
+func (s *ScalableFilter) Contains(v hash.Hash64) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for i := len(s.stages) - 1; i >= 0; i-- {
+		if s.stages[i].Contains(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// N is how many elements have been inserted
+// (actually, how many Add()s have been performed?)
+func (s *ScalableFilter) N() uint64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	n := uint64(0)
+	for _, stage := range s.stages {
+		n += stage.N()
+	}
+	return n
+}
+
+// Stages is how many stages the filter has grown to
+func (s *ScalableFilter) Stages() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.stages)
+}
+
+// FalsePositiveProbability is the upper-bound probability of false positives
+// of any of the stages
+//
+//	1 - (1 - p0) * (1 - p1) * ...
+func (s *ScalableFilter) FalsePositiveProbability() float64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	q := 1.0
+	for _, stage := range s.stages {
+		q *= 1 - stage.FalsePositiveProbability()
+	}
+	return 1 - q
+}
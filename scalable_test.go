@@ -0,0 +1,58 @@
+package bloomfilter
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestScalableFilter(t *testing.T) {
+	const (
+		initialN = 1000
+		p        = 0.01
+		n        = 20 * initialN
+	)
+	s, err := NewScalable(initialN, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	values := make([]hashableUint64, n)
+	for i := range values {
+		values[i] = hashableUint64(rng.Uint64())
+		s.Add(values[i])
+	}
+
+	if s.N() != n {
+		t.Errorf("expected N()=%d, got %d", n, s.N())
+	}
+	if s.Stages() < 2 {
+		t.Errorf("expected the filter to grow past one stage, got %d", s.Stages())
+	}
+	for _, v := range values {
+		if !s.Contains(v) {
+			t.Fatal("definitely does not contain ", v, ", but it should")
+		}
+	}
+
+	if fpp := s.FalsePositiveProbability(); fpp > p {
+		t.Errorf("expected FalsePositiveProbability() <= %g, got %g", p, fpp)
+	}
+
+	falsePositives := 0
+	const trials = 100000
+	for i := 0; i < trials; i++ {
+		if s.Contains(hashableUint64(rng.Uint64())) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / trials; rate > p {
+		t.Errorf("expected a false positive rate <= %g, got %g", p, rate)
+	}
+}
+
+func TestNewScalableInvalid(t *testing.T) {
+	if _, err := NewScalable(1000, 0); err == nil {
+		t.Error("expected an error for p <= 0")
+	}
+}
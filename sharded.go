@@ -0,0 +1,111 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"hash"
+	"math/bits"
+)
+
+// ShardedFilter is a Bloom filter partitioned into independent, compatible
+// shards, each a Filter with its own lock and bits, so that concurrent adds
+// landing in different shards do not contend
+//
+// Each element goes to exactly one shard, so Contains only probes that shard
+type ShardedFilter struct {
+	shards []*Filter // immutable after init
+}
+
+// NewSharded ShardedFilter of the given number of shards, each of m bits
+// and sharing the same k CSPRNG keys
+func NewSharded(shards int, m, k uint64) (*ShardedFilter, error) {
+	if shards < 1 {
+		return nil, errShards()
+	}
+	first, err := New(m, k)
+	if err != nil {
+		return nil, err
+	}
+	s := &ShardedFilter{shards: make([]*Filter, shards)}
+	s.shards[0] = first
+	for i := 1; i < shards; i++ {
+		s.shards[i], err = first.NewCompatible()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// shard picks the shard of rawHash by Fibonacci hashing, which draws on
+// every bit of rawHash; routing by rawHash % shards instead would correlate
+// with the bit positions within the shard, as those come from the low bits,
+// leaving each shard using only some of its bits
+func (s *ShardedFilter) shard(rawHash uint64) *Filter {
+	i, _ := bits.Mul64(rawHash*0x9e3779b97f4a7c15, uint64(len(s.shards)))
+	return s.shards[i]
+}
+
+// Add a hashable item, v, to the filter
+func (s *ShardedFilter) Add(v hash.Hash64) {
+	s.AddRaw(v.Sum64())
+}
+
+// AddRaw adds the item whose 64-bit hash is rawHash, see Filter.AddRaw
+func (s *ShardedFilter) AddRaw(rawHash uint64) {
+	s.shard(rawHash).AddRaw(rawHash)
+}
+
+// Contains tests if s contains v
+// false: s definitely does not contain value v
+// true:  s maybe contains value v
+func (s *ShardedFilter) Contains(v hash.Hash64) bool {
+	return s.ContainsRaw(v.Sum64())
+}
+
+// ContainsRaw tests if s contains the item whose 64-bit hash is rawHash,
+// see Filter.ContainsRaw
+func (s *ShardedFilter) ContainsRaw(rawHash uint64) bool {
+	return s.shard(rawHash).ContainsRaw(rawHash)
+}
+
+// M is the total size of the shards, in bits
+func (s *ShardedFilter) M() uint64 {
+	return uint64(len(s.shards)) * s.shards[0].M()
+}
+
+// K is the count of keys
+func (s *ShardedFilter) K() uint64 {
+	return s.shards[0].K()
+}
+
+// N is how many elements have been inserted, across all shards
+func (s *ShardedFilter) N() uint64 {
+	n := uint64(0)
+	for _, f := range s.shards {
+		n += f.N()
+	}
+	return n
+}
+
+// Shards is the number of shards
+func (s *ShardedFilter) Shards() int {
+	return len(s.shards)
+}
+
+// Merge the shards into a single Filter of the size of one shard, which
+// contains every element added to s
+//
+// As all elements end up in one shard's worth of bits, out has a higher
+// false positive probability than s; size the shards for the merged total
+// if Merge is the goal
+func (s *ShardedFilter) Merge() (out *Filter, err error) {
+	return Merge(s.shards...)
+}
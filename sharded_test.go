@@ -0,0 +1,93 @@
+package bloomfilter
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestShardedFilter(t *testing.T) {
+	s, err := NewSharded(4, 10000, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Shards() != 4 || s.M() != 40000 || s.K() != 5 {
+		t.Errorf("expected (shards=4, m=40000, k=5), got (shards=%d, m=%d, k=%d)",
+			s.Shards(), s.M(), s.K())
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g * 250; i < (g+1)*250; i++ {
+				s.Add(hashableUint64(i))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if s.N() != 1000 {
+		t.Errorf("expected n=1000, got %d", s.N())
+	}
+	for i := 0; i < 1000; i++ {
+		if !s.Contains(hashableUint64(i)) {
+			t.Errorf("sharded filter should contain %d", i)
+		}
+	}
+	for _, f := range s.shards {
+		if f.N() == 0 {
+			t.Error("every shard should receive some elements")
+		}
+	}
+
+	merged, err := s.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.N() != 1000 || merged.M() != 10000 {
+		t.Errorf("expected merged (m=10000, n=1000), got (m=%d, n=%d)", merged.M(), merged.N())
+	}
+	for i := 0; i < 1000; i++ {
+		if !merged.Contains(hashableUint64(i)) {
+			t.Errorf("merged filter should contain %d", i)
+		}
+	}
+}
+
+func TestNewShardedInvalid(t *testing.T) {
+	if _, err := NewSharded(0, 10000, 5); err == nil {
+		t.Error("expected an error for 0 shards")
+	}
+	if _, err := NewSharded(4, 1, 5); err == nil {
+		t.Error("expected an error for m < MMin")
+	}
+}
+
+func BenchmarkAddParallelX10kX5(b *testing.B) {
+	b.StopTimer()
+	bf, _ := New(80000, 5)
+	b.ReportAllocs()
+	b.StartTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			bf.Add(hashableUint64(i))
+			i++
+		}
+	})
+}
+
+func BenchmarkAddShardedParallel8X10kX5(b *testing.B) {
+	b.StopTimer()
+	s, _ := NewSharded(8, 10000, 5)
+	b.ReportAllocs()
+	b.StartTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			s.Add(hashableUint64(i))
+			i++
+		}
+	})
+}
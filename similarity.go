@@ -0,0 +1,124 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"math"
+	"math/bits"
+)
+
+// countAndOr is the number of bits set in both f and f2, and in either; the
+// caller must hold both locks
+func (f *Filter) countAndOr(f2 *Filter) (and, or uint64) {
+	for i := range f.bits {
+		a, b := f.bits[i].Load(), f2.bits[i].Load()
+		and += uint64(bits.OnesCount64(a & b))
+		or += uint64(bits.OnesCount64(a | b))
+	}
+	return and, or
+}
+
+// countEachOr is the number of bits set in f, in f2, and in either; the
+// caller must hold both locks
+func (f *Filter) countEachOr(f2 *Filter) (countA, countB, or uint64) {
+	for i := range f.bits {
+		a, b := f.bits[i].Load(), f2.bits[i].Load()
+		countA += uint64(bits.OnesCount64(a))
+		countB += uint64(bits.OnesCount64(b))
+		or += uint64(bits.OnesCount64(a | b))
+	}
+	return countA, countB, or
+}
+
+// Jaccard estimates the Jaccard index |A∩B|/|A∪B| of the sets held by f
+// and f2, from the estimated element counts of the AND and the OR of their
+// bits
+//
+// Colliding bits make the AND overestimate |A∩B|, so the estimate is biased
+// upwards, most for small overlaps and fuller filters; it is meaningless once
+// either filter nears saturation. Two empty filters have an index of 1
+func (f *Filter) Jaccard(f2 *Filter) (float64, error) {
+	defer rlockPair(f, f2)()
+	if err := f.verifyCompatible(f2); err != nil {
+		return 0, err
+	}
+
+	and, or := f.countAndOr(f2)
+	if or == 0 {
+		return 1, nil
+	}
+	return min(1, float64(f.estimateN(and))/float64(f.estimateN(or))), nil
+}
+
+// IntersectionCardinality estimates the size |A∩B| of the intersection of
+// the sets held by f and f2 by inclusion-exclusion, as |A| + |B| - |A∪B|,
+// with each term estimated from the bits of f, f2 and their OR
+//
+// Unlike estimating from the AND of the bits, this is not biased upwards by
+// colliding bits, but it inherits the error of all three estimates: each is
+// within a few percent of the true count while the filters are well under
+// capacity, and that error is in absolute terms relative to |A∪B|, so small
+// intersections of large sets are imprecise. The estimate is clamped to
+// between 0 and the smaller of |A| and |B|, and is meaningless once either
+// filter nears saturation
+func (f *Filter) IntersectionCardinality(f2 *Filter) (uint64, error) {
+	defer rlockPair(f, f2)()
+	if err := f.verifyCompatible(f2); err != nil {
+		return 0, err
+	}
+
+	countA, countB, or := f.countEachOr(f2)
+	nA, nB := f.estimateN(countA), f.estimateN(countB)
+	estimate := float64(nA) + float64(nB) - float64(f.estimateN(or))
+	if estimate <= 0 {
+		return 0, nil
+	}
+	return min(uint64(math.Round(estimate)), nA, nB), nil
+}
+
+// DifferenceCardinality estimates the size |A \ B| of the set held by f less
+// that held by f2, as |A| - |A∩B| = |A∪B| - |B|, with each term estimated
+// from the bits of f2 and of the OR of f and f2, e.g. how many items of an
+// old snapshot f are gone from a new one f2
+//
+// It has the error bounds of IntersectionCardinality, and is clamped to
+// between 0 and |A|
+func (f *Filter) DifferenceCardinality(f2 *Filter) (uint64, error) {
+	defer rlockPair(f, f2)()
+	if err := f.verifyCompatible(f2); err != nil {
+		return 0, err
+	}
+
+	countA, countB, or := f.countEachOr(f2)
+	nA, nOr := f.estimateN(countA), f.estimateN(or)
+	estimate := float64(nOr) - float64(f.estimateN(countB))
+	if estimate <= 0 {
+		return 0, nil
+	}
+	return min(uint64(math.Round(estimate)), nA, nOr), nil
+}
+
+// HasOverlap is whether f and f2 have any set bit in common, stopping at the
+// first, which is much cheaper than Intersect
+// false: f and f2 definitely have no element in common
+// true:  f and f2 maybe have an element in common, or just colliding bits
+func (f *Filter) HasOverlap(f2 *Filter) (bool, error) {
+	defer rlockPair(f, f2)()
+	if err := f.verifyCompatible(f2); err != nil {
+		return false, err
+	}
+
+	for i := range f.bits {
+		if f.bits[i].Load()&f2.bits[i].Load() != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
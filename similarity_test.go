@@ -0,0 +1,147 @@
+package bloomfilter
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// overlappingFilters fills two compatible filters with a and b random items,
+// of which common are in both
+func overlappingFilters(m, k uint64, a, b, common int) (*Filter, *Filter) {
+	rng := rand.New(rand.NewSource(1))
+	f, _ := New(m, k)
+	f2, _ := f.NewCompatible()
+	for i := 0; i < common; i++ {
+		v := hashableUint64(rng.Uint64())
+		f.Add(v)
+		f2.Add(v)
+	}
+	for i := common; i < a; i++ {
+		f.Add(hashableUint64(rng.Uint64()))
+	}
+	for i := common; i < b; i++ {
+		f2.Add(hashableUint64(rng.Uint64()))
+	}
+	return f, f2
+}
+
+func TestJaccard(t *testing.T) {
+	// |A∩B| = 500, |A∪B| = 1500
+	f, f2 := overlappingFilters(100000, 7, 1000, 1000, 500)
+
+	j, err := f.Jaccard(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(j-1.0/3) > 0.05 {
+		t.Errorf("expected Jaccard()≈%f, got %f", 1.0/3, j)
+	}
+
+	empty, _ := f.NewCompatible()
+	empty2, _ := f.NewCompatible()
+	if j, _ := empty.Jaccard(empty2); j != 1 {
+		t.Errorf("expected Jaccard()=1 of two empty filters, got %f", j)
+	}
+}
+
+func TestJaccardIncompatible(t *testing.T) {
+	f, _ := New(1000, 5)
+	f2, _ := New(1000, 5)
+	if _, err := f.Jaccard(f2); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("expected %v, got %v", ErrIncompatible, err)
+	}
+}
+
+func TestHasOverlap(t *testing.T) {
+	f, _ := NewWithSeed(100000, 5, 1)
+	f2, _ := f.NewCompatible()
+	f.Add(hashableUint64(1))
+	f2.Add(hashableUint64(2))
+
+	overlap, err := f.HasOverlap(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overlap {
+		t.Error("filters of different items should not overlap")
+	}
+
+	f2.Add(hashableUint64(1))
+	overlap, err = f.HasOverlap(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !overlap {
+		t.Error("filters sharing an item should overlap")
+	}
+
+	incompatible, _ := New(100000, 5)
+	if _, err := f.HasOverlap(incompatible); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("expected ErrIncompatible, got %v", err)
+	}
+}
+
+func TestIntersectionCardinality(t *testing.T) {
+	tests := []struct {
+		a, b, common int
+	}{
+		{a: 1000, b: 1000, common: 500},
+		{a: 2000, b: 1000, common: 100},
+		{a: 1000, b: 1000, common: 0},
+		{a: 1000, b: 1000, common: 1000},
+	}
+
+	for _, test := range tests {
+		f, f2 := overlappingFilters(100000, 7, test.a, test.b, test.common)
+		n, err := f.IntersectionCardinality(f2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// within 5% of |A∪B|
+		tolerance := 0.05 * float64(test.a+test.b-test.common)
+		if math.Abs(float64(n)-float64(test.common)) > tolerance {
+			t.Errorf("%+v: expected IntersectionCardinality()≈%d, got %d", test, test.common, n)
+		}
+	}
+
+	f, _ := New(100000, 7)
+	incompatible, _ := New(100000, 7)
+	if _, err := f.IntersectionCardinality(incompatible); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("expected ErrIncompatible, got %v", err)
+	}
+}
+
+func TestDifferenceCardinality(t *testing.T) {
+	tests := []struct {
+		a, b, common int
+	}{
+		// B is a subset of A
+		{a: 2000, b: 500, common: 500},
+		// A is a subset of B
+		{a: 500, b: 2000, common: 500},
+		{a: 1000, b: 1000, common: 500},
+		{a: 1000, b: 1000, common: 0},
+	}
+
+	for _, test := range tests {
+		f, f2 := overlappingFilters(100000, 7, test.a, test.b, test.common)
+		n, err := f.DifferenceCardinality(f2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := test.a - test.common
+		// within 5% of |A∪B|
+		tolerance := 0.05 * float64(test.a+test.b-test.common)
+		if math.Abs(float64(n)-float64(expected)) > tolerance {
+			t.Errorf("%+v: expected DifferenceCardinality()≈%d, got %d", test, expected, n)
+		}
+	}
+
+	f, _ := New(100000, 7)
+	incompatible, _ := New(100000, 7)
+	if _, err := f.DifferenceCardinality(incompatible); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("expected ErrIncompatible, got %v", err)
+	}
+}
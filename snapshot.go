@@ -0,0 +1,35 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+// Snapshot of f as a Filter out that shares the bits of f rather than
+// copying them like Copy, so that it is cheap to take; out keeps the state
+// f had at the time regardless of later changes to f
+//
+// The first write to either f or out after a Snapshot copies the bits for
+// the writer (copy-on-write), so a snapshot costs a Copy, once, only when f
+// keeps changing; writes to out do not show in f either. out is compatible
+// with f, and always locks, even when f is from NewUnsafe
+func (f *Filter) Snapshot() *Filter {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	out := &Filter{
+		m:         f.m,
+		keys:      f.keys, // immutable, so safe to share
+		bits:      f.bits,
+		positions: f.positions,
+		seed:      f.seed,
+		seeded:    f.seeded,
+	}
+	out.n.Store(f.n.Load())
+	out.shared.Store(true)
+	f.shared.Store(true)
+	return out
+}
@@ -0,0 +1,113 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	f, _ := New(10000, 5)
+	for i := 0; i < 100; i++ {
+		f.Add(hashableUint64(i))
+	}
+	snap := f.Snapshot()
+	if !snap.Equal(f) || !snap.IsCompatible(f) {
+		t.Fatal("a snapshot should equal its source")
+	}
+
+	for i := 100; i < 1100; i++ {
+		f.Add(hashableUint64(i))
+	}
+	if snap.N() != 100 {
+		t.Errorf("snapshot should not see later adds, got n=%d", snap.N())
+	}
+	for i := 0; i < 100; i++ {
+		if !snap.Contains(hashableUint64(i)) {
+			t.Errorf("snapshot should contain %d", i)
+		}
+	}
+	misses := 0
+	for i := 100; i < 1100; i++ {
+		if !snap.Contains(hashableUint64(i)) {
+			misses++
+		}
+		if !f.Contains(hashableUint64(i)) {
+			t.Errorf("source should contain %d", i)
+		}
+	}
+	if misses < 990 {
+		t.Errorf("snapshot should not see later adds, found %d of 1000", 1000-misses)
+	}
+
+	snap.Add(hashableUint64(100000))
+	f.Clear()
+	if !snap.Contains(hashableUint64(100000)) || f.Contains(hashableUint64(100000)) {
+		t.Error("writes to a snapshot and its source should stay apart")
+	}
+}
+
+func TestSnapshotReadFrom(t *testing.T) {
+	f, _ := New(10000, 5)
+	for i := 0; i < 100; i++ {
+		f.Add(hashableUint64(i))
+	}
+	snap := f.Snapshot()
+	want := snap.BitSet()
+
+	other, _ := New(10000, 5)
+	for i := 1000; i < 1100; i++ {
+		other.Add(hashableUint64(i))
+	}
+	var b bytes.Buffer
+	if _, err := other.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.ReadFrom(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(snap.BitSet(), want) || snap.N() != 100 {
+		t.Error("ReadFrom into the source should not change its snapshot")
+	}
+	if !f.Equal(other) {
+		t.Error("expected the source to hold what was read")
+	}
+}
+
+func TestSnapshotConcurrent(t *testing.T) {
+	f, _ := New(10000, 5)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				f.Add(hashableUint64(g*1000 + i))
+			}
+		}(g)
+	}
+	var snaps []*Filter
+	var bitSets [][]uint64
+	for i := 0; i < 20; i++ {
+		snap := f.Snapshot()
+		snaps = append(snaps, snap)
+		bitSets = append(bitSets, snap.BitSet())
+	}
+	wg.Wait()
+
+	for i, snap := range snaps {
+		for w, word := range snap.BitSet() {
+			if word != bitSets[i][w] {
+				t.Fatalf("snapshot %d changed after it was taken", i)
+			}
+		}
+	}
+
+	for i := 0; i < 4000; i++ {
+		if !f.Contains(hashableUint64(i)) {
+			t.Errorf("source should contain %d", i)
+		}
+	}
+}
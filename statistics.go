@@ -11,6 +11,8 @@ package bloomfilter
 
 import (
 	"math"
+	"math/bits"
+	"unsafe"
 )
 
 // N is how many elements have been inserted
@@ -19,12 +21,209 @@ func (f *Filter) N() uint64 {
 	return f.n.Load()
 }
 
-// FalsePosititveProbability is the upper-bound probability of false positives
+// FalsePositiveProbability is the upper-bound probability of false positives
 //
 //	(1 - exp(-k*(n+0.5)/(m-1))) ** k
+//
+// or 0 for an empty filter
+func (f *Filter) FalsePositiveProbability() float64 {
+	return f.FalsePositiveProbabilityAt(f.N())
+}
+
+// FalsePosititveProbability is FalsePositiveProbability
+//
+// Deprecated: use the correctly spelled FalsePositiveProbability.
 func (f *Filter) FalsePosititveProbability() float64 {
+	return f.FalsePositiveProbability()
+}
+
+// FalsePositiveProbabilityAt is the upper-bound probability of false
+// positives once n elements have been inserted, for capacity planning
+//
+//	(1 - exp(-k*(n+0.5)/(m-1))) ** k
+func (f *Filter) FalsePositiveProbabilityAt(n uint64) float64 {
+	return falsePositiveProbability(f.M(), f.K(), n)
+}
+
+// falsePositiveProbability is 0 when nothing has been inserted, and 1 for a
+// degenerate m <= 1, where the formula would divide by zero
+func falsePositiveProbability(m, k, n uint64) float64 {
+	switch {
+	case n == 0:
+		return 0
+	case m <= 1:
+		return 1
+	}
+	kf := float64(k)
+	return math.Pow(1.0-math.Exp(-kf*(float64(n)+0.5)/(float64(m)-1)), kf)
+}
+
+// Cap is the estimated number of elements f can hold before its false
+// positive probability exceeds p, the inverse of FalsePositiveProbabilityAt
+//
+//	-((m-1)/k) * ln(1 - p ** (1/k)) - 0.5
+//
+// returns 0 for p <= 0 and math.MaxUint64 for p >= 1
+func (f *Filter) Cap(p float64) uint64 {
+	switch {
+	case !(p > 0):
+		return 0
+	case p >= 1:
+		return math.MaxUint64
+	}
+	k := float64(f.K())
+	m := float64(f.M())
+	n := -((m-1)/k)*math.Log1p(-math.Pow(p, 1/k)) - 0.5
+	if !(n > 0) {
+		return 0
+	}
+	if n >= math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return uint64(n)
+}
+
+// LoadFactor is EstimateN() over the capacity of f at which half of its
+// bits are set, m * ln(2) / k; a filter from NewOptimal(maxN, p) reaches a
+// false positive probability of about p at a LoadFactor of 1
+func (f *Filter) LoadFactor() float64 {
+	capacity := float64(f.M()) * math.Ln2 / float64(f.K())
+	return float64(f.EstimateN()) / capacity
+}
+
+// IsEmpty is true if no bits are set, which is cheaper than Count() == 0
+func (f *Filter) IsEmpty() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	for i := range f.bits {
+		if f.bits[i].Load() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSaturated is true once the fraction of set bits, Count()/M(), exceeds
+// threshold, e.g. 0.5, which with the optimal k is where the false positive
+// probability starts climbing steeply, as a cheap check before adding more
+// or rotating to a new filter
+func (f *Filter) IsSaturated(threshold float64) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return float64(f.count())/float64(f.M()) > threshold
+}
+
+// Count is the number of set bits, Count()/M() is the true fill ratio
+func (f *Filter) Count() uint64 {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.count()
+}
+
+// WordPopulationHistogram counts, at index c for each popcount c from 0 to
+// 64, how many 64-bit words of the bits have c bits set; the bits of a
+// healthy filter land independently, giving a roughly binomial histogram
+// around 64*Count()/M(), so lopsided or lumpy ones point at a poor hash
+// function. The last word counts only its bits below M()
+func (f *Filter) WordPopulationHistogram() []int {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	histogram := make([]int, 65)
+	for i := range f.bits {
+		histogram[bits.OnesCount64(f.bits[i].Load())]++
+	}
+	return histogram
+}
+
+// EstimateN estimates how many distinct elements have been inserted, from
+// the density of set bits, so unlike N() it does not count repeated Add()s
+//
+//	-(m/k) * ln(1 - Count()/m)
+//
+// returns math.MaxUint64 when every bit is set, as the count is unknowable
+func (f *Filter) EstimateN() uint64 {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.estimateN(f.count())
+}
+
+// SetN overrides how many elements f is taken to hold, which drives
+// FalsePositiveProbability, for recalibrating after unions
+func (f *Filter) SetN(n uint64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.n.Store(n)
+}
+
+// RecountN sets N() to EstimateN(), the distinct elements judged from the
+// set bits, which after unions of overlapping filters is a more honest count
+// than the summed Add()s, and returns it
+func (f *Filter) RecountN() uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	n := f.estimateN(f.count())
+	f.n.Store(n)
+	return n
+}
+
+// PreciseFilledRatio is an exhaustive count # of 1's
+func (f *Filter) PreciseFilledRatio() float64 {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return float64(f.count()) / float64(f.M())
+}
+
+// count is the number of set bits; the caller must hold f.lock
+func (f *Filter) count() uint64 {
+	ones := 0
+	for i := range f.bits {
+		ones += bits.OnesCount64(f.bits[i].Load())
+	}
+	return uint64(ones)
+}
+
+// estimateN estimates the count of distinct elements from x set bits
+//
+//	-(m/k) * ln(1 - x/m)
+//
+// returns math.MaxUint64 when every bit is set, as the count is unknowable
+func (f *Filter) estimateN(x uint64) uint64 {
+	if x >= f.m {
+		return math.MaxUint64
+	}
 	k := float64(f.K())
-	n := float64(f.N())
 	m := float64(f.M())
-	return math.Pow(1.0-math.Exp(-k)*(n+0.5)/(m-1), k)
+	return uint64(math.Round(-(m / k) * math.Log1p(-float64(x)/m)))
+}
+
+// SizeInBytes is the approximate memory footprint of f: its bits, its keys
+// and the Filter itself, but not any PositionFunc
+func (f *Filter) SizeInBytes() uint64 {
+	words := (f.M() + 63) / 64
+	return uint64(unsafe.Sizeof(*f)) + (words+f.K())*Uint64Bytes
+}
+
+// Stats is a snapshot of the health of a filter
+type Stats struct {
+	M                          uint64  // size of the filter, in bits
+	K                          uint64  // count of keys
+	N                          uint64  // how many Add()s have been performed
+	SetBits                    uint64  // number of set bits
+	FillRatio                  float64 // SetBits / M
+	EstimatedFalsePositiveRate float64 // FalsePositiveProbability at N
+}
+
+// Stats of f, all taken together from one consistent view of the bits
+func (f *Filter) Stats() Stats {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	s := Stats{
+		M:       f.M(),
+		K:       f.K(),
+		N:       f.n.Load(),
+		SetBits: f.count(),
+	}
+	s.FillRatio = float64(s.SetBits) / float64(s.M)
+	s.EstimatedFalsePositiveRate = f.FalsePositiveProbabilityAt(s.N)
+	return s
 }
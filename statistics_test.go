@@ -0,0 +1,325 @@
+package bloomfilter
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFalsePositiveProbability(t *testing.T) {
+	tests := []struct {
+		m, k, n uint64
+		p       float64
+	}{
+		{m: 1000, k: 3, n: 0, p: 0},
+		{m: 1000, k: 3, n: 100, p: 0.01768072742336996},
+		{m: 10000, k: 7, n: 1000, p: 0.008217511741516425},
+		{m: 100, k: 5, n: 100, p: 0.9691559768636809},
+		{m: 19171, k: 14, n: 1000, p: 0.00010127168986652121},
+	}
+
+	for _, test := range tests {
+		f, err := New(test.m, test.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.n.Store(test.n)
+
+		p := f.FalsePositiveProbability()
+		if math.Abs(p-test.p) > 1e-12*math.Max(1, test.p) {
+			t.Errorf(
+				"m=%d k=%d n=%d: expected p=%g, got p=%g",
+				test.m,
+				test.k,
+				test.n,
+				test.p,
+				p,
+			)
+		}
+	}
+}
+
+func TestFalsePositiveProbabilityDegenerate(t *testing.T) {
+	f, _ := New(MMin, KMin)
+	if p := f.FalsePositiveProbability(); p != 0 {
+		t.Errorf("expected an empty filter to have p=0, got %g", p)
+	}
+
+	tests := []struct {
+		m, k, n uint64
+		p       float64
+	}{
+		{m: 0, k: 3, n: 10, p: 1},
+		{m: 1, k: 3, n: 10, p: 1},
+		{m: 1, k: 3, n: 0, p: 0},
+		{m: 2, k: 1, n: math.MaxUint64, p: 1},
+	}
+	for _, test := range tests {
+		p := falsePositiveProbability(test.m, test.k, test.n)
+		if math.IsNaN(p) || p < 0 || p > 1 || p != test.p {
+			t.Errorf("m=%d k=%d n=%d: expected p=%g, got p=%g", test.m, test.k, test.n, test.p, p)
+		}
+	}
+}
+
+func TestFalsePosititveProbabilityDeprecated(t *testing.T) {
+	f, _ := New(10000, 5)
+	for _, n := range []uint64{0, 10, 1000, 100000} {
+		f.n.Store(n)
+		if f.FalsePosititveProbability() != f.FalsePositiveProbability() {
+			t.Errorf("n=%d: FalsePosititveProbability()=%g and FalsePositiveProbability()=%g differ",
+				n, f.FalsePosititveProbability(), f.FalsePositiveProbability())
+		}
+	}
+}
+
+func TestFalsePositiveProbabilityAt(t *testing.T) {
+	f, _ := New(10000, 5)
+	f.n.Store(1234)
+	if f.FalsePositiveProbabilityAt(f.N()) != f.FalsePositiveProbability() {
+		t.Error("FalsePositiveProbabilityAt(N()) should match FalsePositiveProbability()")
+	}
+
+	last := f.FalsePositiveProbabilityAt(0)
+	for n := uint64(100); n <= 100000; n += 100 {
+		p := f.FalsePositiveProbabilityAt(n)
+		if p < last {
+			t.Fatalf("n=%d: p=%g decreased from %g", n, p, last)
+		}
+		last = p
+	}
+}
+
+func TestCount(t *testing.T) {
+	f, _ := New(10000, 5)
+	if f.Count() != 0 {
+		t.Errorf("expected Count()=0, got %d", f.Count())
+	}
+
+	for i := 0; i < 100; i++ {
+		f.Add(hashableUint64(i))
+	}
+
+	c := f.Count()
+	if c == 0 || c > f.K()*f.N() {
+		t.Errorf("expected 0 < Count() <= %d, got %d", f.K()*f.N(), c)
+	}
+	if ratio := float64(c) / float64(f.M()); ratio != f.PreciseFilledRatio() {
+		t.Errorf("expected PreciseFilledRatio()=%f, got %f", ratio, f.PreciseFilledRatio())
+	}
+}
+
+func TestWordPopulationHistogram(t *testing.T) {
+	f, _ := New(64000, 5)
+	state, out := uint64(0), uint64(0)
+	for i := 0; i < 8000; i++ {
+		state, out = splitmix64(state)
+		f.Add(hashableUint64(out))
+	}
+
+	histogram := f.WordPopulationHistogram()
+	if len(histogram) != 65 {
+		t.Fatalf("expected 65 popcounts, got %d", len(histogram))
+	}
+	words, ones := 0, 0
+	for c, count := range histogram {
+		words += count
+		ones += c * count
+	}
+	if words != 1000 || uint64(ones) != f.Count() {
+		t.Errorf("expected 1000 words with %d bits set, got %d with %d", f.Count(), words, ones)
+	}
+
+	// about 46% full, so popcounts are about binomial(64, 0.46): mean 29.5,
+	// standard deviation 4
+	mean := 64 * float64(f.Count()) / float64(f.M())
+	near := 0
+	for c := int(mean) - 10; c <= int(mean)+10; c++ {
+		near += histogram[c]
+	}
+	if near < 980 {
+		t.Errorf("expected most words within 10 bits of %.1f set, got %d of 1000: %v", mean, near, histogram)
+	}
+}
+
+func TestEstimateN(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{100, 1000, 5000} {
+		f, _ := New(100000, 5)
+		values := make([]hashableUint64, n)
+		for i := range values {
+			values[i] = hashableUint64(rng.Uint64())
+		}
+		// duplicate Add()s must not change the estimate
+		for j := 0; j < 2; j++ {
+			for _, v := range values {
+				f.Add(v)
+			}
+		}
+
+		est := f.EstimateN()
+		if math.Abs(float64(est)-float64(n)) > 0.03*float64(n) {
+			t.Errorf("n=%d: EstimateN()=%d is not within 3%%", n, est)
+		}
+	}
+}
+
+func TestEstimateNSaturated(t *testing.T) {
+	f, _ := New(64, 1)
+	for i := range f.bits {
+		f.bits[i].Store(math.MaxUint64)
+	}
+	if f.EstimateN() != math.MaxUint64 {
+		t.Errorf("expected EstimateN()=%d, got %d", uint64(math.MaxUint64), f.EstimateN())
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	f, _ := New(10000, 5)
+	if !f.IsEmpty() {
+		t.Error("a new filter should be empty")
+	}
+
+	f.Clear()
+	if !f.IsEmpty() {
+		t.Error("a cleared new filter should be empty")
+	}
+
+	f.Add(hashableUint64(7))
+	if f.IsEmpty() {
+		t.Error("a filter should not be empty after an Add()")
+	}
+}
+
+func TestIsSaturated(t *testing.T) {
+	f, _ := New(1000, 5)
+	if f.IsSaturated(0) {
+		t.Error("an empty filter should not be saturated")
+	}
+	for i := 0; f.Count() < 500; i++ {
+		if f.IsSaturated(0.5) {
+			t.Fatalf("saturated at %d of 1000 bits set", f.Count())
+		}
+		f.Add(hashableUint64(i))
+	}
+	for i := 1 << 40; f.Count() == 500; i++ {
+		if f.IsSaturated(0.5) {
+			t.Fatal("exactly half set should not exceed 0.5")
+		}
+		f.Add(hashableUint64(i))
+	}
+	if !f.IsSaturated(0.5) {
+		t.Errorf("expected saturation at %d of 1000 bits set", f.Count())
+	}
+	if f.IsSaturated(1) {
+		t.Error("no filter is saturated past a threshold of 1")
+	}
+}
+
+func TestStats(t *testing.T) {
+	f, _ := New(10000, 5)
+	for i := 0; i < 100; i++ {
+		f.Add(hashableUint64(i))
+	}
+
+	s := f.Stats()
+	if s.M != f.M() || s.K != f.K() || s.N != f.N() || s.SetBits != f.Count() {
+		t.Errorf("expected (m=%d, k=%d, n=%d, set bits=%d), got %+v",
+			f.M(), f.K(), f.N(), f.Count(), s)
+	}
+	if s.FillRatio != float64(s.SetBits)/float64(s.M) {
+		t.Errorf("expected FillRatio=%f, got %f", float64(s.SetBits)/float64(s.M), s.FillRatio)
+	}
+	if s.EstimatedFalsePositiveRate != f.FalsePositiveProbability() {
+		t.Errorf("expected EstimatedFalsePositiveRate=%g, got %g",
+			f.FalsePositiveProbability(), s.EstimatedFalsePositiveRate)
+	}
+}
+
+func TestCap(t *testing.T) {
+	const p = 0.01
+	f, _ := NewOptimal(10000, p)
+
+	capacity := f.Cap(p)
+	if capacity < 9500 || capacity > 10500 {
+		t.Errorf("expected Cap(%g)≈10000, got %d", p, capacity)
+	}
+	if at := f.FalsePositiveProbabilityAt(capacity); math.Abs(at-p) > 0.0001 {
+		t.Errorf("expected FalsePositiveProbabilityAt(Cap(p))≈%g, got %g", p, at)
+	}
+	if f.Cap(0) != 0 || f.Cap(1) != math.MaxUint64 {
+		t.Errorf("expected Cap(0)=0 and Cap(1)=MaxUint64, got %d and %d", f.Cap(0), f.Cap(1))
+	}
+}
+
+func TestLoadFactor(t *testing.T) {
+	const p = 0.01
+	f, _ := NewOptimal(10000, p)
+	if f.LoadFactor() != 0 {
+		t.Errorf("expected an empty filter to have LoadFactor()=0, got %f", f.LoadFactor())
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for f.LoadFactor() < 1 {
+		for i := 0; i < 100; i++ {
+			f.Add(hashableUint64(rng.Uint64()))
+		}
+	}
+
+	if fpp := f.FalsePositiveProbability(); fpp < p/2 || fpp > p*1.5 {
+		t.Errorf("expected FalsePositiveProbability()≈%g at LoadFactor()=1, got %g", p, fpp)
+	}
+	falsePositives := 0
+	for i := 0; i < 100000; i++ {
+		if f.Contains(hashableUint64(rng.Uint64())) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 100000; rate < p/2 || rate > p*1.5 {
+		t.Errorf("expected a false positive rate ≈%g at LoadFactor()=1, got %g", p, rate)
+	}
+}
+
+func TestSizeInBytes(t *testing.T) {
+	for _, m := range []uint64{MMin, 64, 65, 10000, 1 << 20} {
+		f, _ := New(m, 5)
+		words := (m + 63) / 64
+		if size := f.SizeInBytes(); size < words*8+5*8 {
+			t.Errorf("m=%d: expected at least %d byte(s), got %d", m, words*8+5*8, size)
+		}
+	}
+
+	small, _ := New(1000, 5)
+	large, _ := New(2000, 5)
+	if large.SizeInBytes()-small.SizeInBytes() != (32-16)*8 {
+		t.Errorf("expected %d byte(s) more for 1000 more bits, got %d",
+			(32-16)*8, large.SizeInBytes()-small.SizeInBytes())
+	}
+}
+
+func TestSetN(t *testing.T) {
+	f, _ := New(1000, 5)
+	f.SetN(42)
+	if f.N() != 42 {
+		t.Errorf("expected N()=42, got %d", f.N())
+	}
+}
+
+func TestRecountN(t *testing.T) {
+	// 1000 items each, 500 of them in both
+	f, f2 := overlappingFilters(100000, 7, 1000, 1000, 500)
+	if err := f.UnionInPlace(f2); err != nil {
+		t.Fatal(err)
+	}
+	if f.N() != 2000 {
+		t.Fatalf("expected the naive N()=2000, got %d", f.N())
+	}
+
+	n := f.RecountN()
+	if n != f.N() {
+		t.Errorf("RecountN()=%d should set N(), got %d", n, f.N())
+	}
+	if n >= 2000 || n < 1400 || n > 1600 {
+		t.Errorf("expected RecountN()≈1500, below the naive 2000, got %d", n)
+	}
+}
@@ -19,7 +19,7 @@ func (f *Filter) MarshalText() (text []byte, err error) {
 	s := fmt.Sprintln("k")
 	s += fmt.Sprintln(f.K())
 	s += fmt.Sprintln("n")
-	s += fmt.Sprintln(f.n)
+	s += fmt.Sprintln(f.n.Load())
 	s += fmt.Sprintln("m")
 	s += fmt.Sprintln(f.m)
 
@@ -29,8 +29,8 @@ func (f *Filter) MarshalText() (text []byte, err error) {
 	}
 
 	s += fmt.Sprintln("bits")
-	for _, w := range f.bits {
-		s += fmt.Sprintf(bitsFormat, w.Load()) + nl()
+	for i := range f.bits {
+		s += fmt.Sprintf(bitsFormat, f.bits[i].Load()) + nl()
 	}
 
 	_, hash, err := f.marshal()
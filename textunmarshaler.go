@@ -144,9 +144,11 @@ func (f *Filter) UnmarshalText(text []byte) error {
 	}
 
 	f.m = f2.m
-	f.n = f2.n
+	f.n.Store(f2.n.Load())
 	f.setBits(f2.getBits())
 	copy(f.keys, f2.keys)
+	f.seed, f.seeded = 0, false
+	f.pooled = false
 
 	return nil
 }
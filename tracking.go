@@ -0,0 +1,101 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"hash"
+	"sync"
+)
+
+// TrackingFilter is a Filter that also keeps the raw hash of every element
+// added through it, so that it can be rebuilt at another size by Grow,
+// which a Filter alone cannot do losslessly
+//
+// Tracking costs 8 bytes per Add, repeats included, on top of the filter,
+// and grows without bound; it suits filters that must grow but whose
+// elements are too costly to keep or re-read in full
+type TrackingFilter struct {
+	f      *Filter
+	lock   sync.Mutex
+	hashes []uint64 // raw hashes of everything added through Add
+}
+
+// NewTracking wraps f to track the elements added to it from now on; f
+// should be empty, and only be added to through the wrapper, as elements
+// added otherwise are lost by Grow
+func NewTracking(f *Filter) *TrackingFilter {
+	return &TrackingFilter{f: f}
+}
+
+// Unwrap is the underlying Filter, for Union()s, marshalling and the like
+func (t *TrackingFilter) Unwrap() *Filter {
+	return t.f
+}
+
+// Add v to the filter, and track it
+func (t *TrackingFilter) Add(v hash.Hash64) {
+	t.AddRaw(v.Sum64())
+}
+
+// AddRaw adds the item whose 64-bit hash is rawHash, see Filter.AddRaw, and
+// tracks it
+func (t *TrackingFilter) AddRaw(rawHash uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.hashes = append(t.hashes, rawHash)
+	t.f.AddRaw(rawHash)
+}
+
+// Contains tests if the filter contains v
+// false: definitely does not contain v
+// true:  maybe contains v
+func (t *TrackingFilter) Contains(v hash.Hash64) bool {
+	return t.f.Contains(v)
+}
+
+// M is the size of the Bloom filter, in bits
+func (t *TrackingFilter) M() uint64 {
+	return t.f.M()
+}
+
+// K is the count of keys
+func (t *TrackingFilter) K() uint64 {
+	return t.f.K()
+}
+
+// N is how many elements have been inserted
+func (t *TrackingFilter) N() uint64 {
+	return t.f.N()
+}
+
+// Grow rebuilds the filter into a new TrackingFilter out of newM bits, with
+// the same keys and PositionFunc, by re-adding every tracked element, so
+// out contains all of them at the lower false positive probability of its
+// size; newM may also be smaller, to shrink a filter
+func (t *TrackingFilter) Grow(newM uint64) (out *TrackingFilter, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	f, err := NewWithKeys(newM, t.f.keys)
+	if err != nil {
+		return nil, err
+	}
+	f.positions = t.f.positions
+
+	out = &TrackingFilter{f: f, hashes: make([]uint64, len(t.hashes))}
+	copy(out.hashes, t.hashes)
+	f.lock.RLock()
+	for _, rawHash := range out.hashes {
+		f.add(rawHash)
+	}
+	f.lock.RUnlock()
+	f.n.Store(uint64(len(out.hashes)))
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package bloomfilter
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestTrackingFilterGrow(t *testing.T) {
+	const capacity, n = 1000, 5000
+	f, _ := NewOptimal(capacity, 0.01)
+	tf := NewTracking(f)
+
+	rng := rand.New(rand.NewSource(1))
+	values := make([]hashableUint64, n)
+	for i := range values {
+		values[i] = hashableUint64(rng.Uint64())
+		tf.Add(values[i])
+	}
+	probes := make([]hashableUint64, 100000)
+	for i := range probes {
+		probes[i] = hashableUint64(rng.Uint64())
+	}
+	falsePositiveRate := func(tf *TrackingFilter) float64 {
+		count := 0
+		for _, v := range probes {
+			if tf.Contains(v) {
+				count++
+			}
+		}
+		return float64(count) / float64(len(probes))
+	}
+
+	grown, err := tf.Grow(OptimalM(n, 0.01))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grown.M() != OptimalM(n, 0.01) || grown.K() != tf.K() || grown.N() != n {
+		t.Errorf("expected (m=%d, k=%d, n=%d), got (m=%d, k=%d, n=%d)",
+			OptimalM(n, 0.01), tf.K(), n, grown.M(), grown.K(), grown.N())
+	}
+	for _, v := range values {
+		if !grown.Contains(v) {
+			t.Fatalf("grown filter definitely does not contain %d, but it should", v)
+		}
+	}
+	before, after := falsePositiveRate(tf), falsePositiveRate(grown)
+	if after >= before/2 {
+		t.Errorf("expected growing to cut the false positive rate of %g, got %g", before, after)
+	}
+
+	// the grown filter keeps tracking
+	grown.Add(hashableUint64(1))
+	again, err := grown.Grow(2 * grown.M())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.N() != n+1 || !again.Contains(hashableUint64(1)) {
+		t.Errorf("expected the regrown filter to hold n=%d, got %d", n+1, again.N())
+	}
+
+	if _, err := tf.Grow(1); err == nil {
+		t.Error("expected an error for m < MMin")
+	}
+}
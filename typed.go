@@ -0,0 +1,63 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import (
+	"hash"
+)
+
+// TypedFilter is a Filter of T, hashed by a user-supplied function
+type TypedFilter[T any] struct {
+	f      *Filter
+	hasher func(T) hash.Hash64
+}
+
+// NewTyped wraps f to hold values of T, each hashed with hasher
+func NewTyped[T any](f *Filter, hasher func(T) hash.Hash64) *TypedFilter[T] {
+	return &TypedFilter[T]{f: f, hasher: hasher}
+}
+
+// Unwrap is the underlying Filter, for Union()s, marshalling and the like
+func (t *TypedFilter[T]) Unwrap() *Filter {
+	return t.f
+}
+
+// Add v to the filter
+func (t *TypedFilter[T]) Add(v T) {
+	t.f.Add(t.hasher(v))
+}
+
+// AddC adds v to the filter, testing for its presence beforehand, see
+// Filter.AddC
+func (t *TypedFilter[T]) AddC(v T) bool {
+	return t.f.AddC(t.hasher(v))
+}
+
+// Contains tests if the filter contains v
+// false: definitely does not contain v
+// true:  maybe contains v
+func (t *TypedFilter[T]) Contains(v T) bool {
+	return t.f.Contains(t.hasher(v))
+}
+
+// M is the size of the Bloom filter, in bits
+func (t *TypedFilter[T]) M() uint64 {
+	return t.f.M()
+}
+
+// K is the count of keys
+func (t *TypedFilter[T]) K() uint64 {
+	return t.f.K()
+}
+
+// N is how many elements have been inserted
+func (t *TypedFilter[T]) N() uint64 {
+	return t.f.N()
+}
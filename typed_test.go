@@ -0,0 +1,46 @@
+package bloomfilter
+
+import (
+	"hash"
+	"hash/fnv"
+	"testing"
+)
+
+func TestTypedFilterString(t *testing.T) {
+	f, _ := New(10000, 5)
+	tf := NewTyped(f, func(s string) hash.Hash64 {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(s))
+		return h
+	})
+
+	tf.Add("x")
+	if !tf.Contains("x") {
+		t.Error("definitely does not contain x, but it should")
+	}
+	if tf.Contains("an unrelated key") {
+		t.Error("may contain an unrelated key, but almost surely should not")
+	}
+	if !tf.Unwrap().ContainsString("x") {
+		t.Error("Unwrap() is not the underlying filter")
+	}
+	if tf.N() != 1 || tf.M() != f.M() || tf.K() != f.K() {
+		t.Error("TypedFilter does not report the underlying filter")
+	}
+}
+
+func TestTypedFilterInt(t *testing.T) {
+	f, _ := New(10000, 5)
+	tf := NewTyped(f, func(i int) hash.Hash64 {
+		return hashableUint64(i)
+	})
+
+	for _, x := range hashableUint64Values() {
+		tf.Add(int(x))
+	}
+	for _, x := range hashableUint64Values() {
+		if !tf.Contains(int(x)) || !f.Contains(x) {
+			t.Error("definitely does not contain ", x, ", but it should")
+		}
+	}
+}
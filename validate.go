@@ -0,0 +1,41 @@
+// Package bloomfilter is face-meltingly fast, thread-safe,
+// marshalable, unionable, probability- and
+// optimal-size-calculating Bloom filter in go
+//
+// https://github.com/steakknife/bloomfilter
+//
+// # Copyright © 2014, 2015, 2018 Barry Allard
+//
+// MIT license
+package bloomfilter
+
+import "fmt"
+
+// Validate checks the invariants of f, for sanity-checking a filter loaded
+// from an untrusted source before using it: m >= MMin, at least KMin keys,
+// all unique, exactly (m+63)/64 words of bits, and no bits set past m
+//
+// Filters from NewUnchecked fail it when below MMin
+func (f *Filter) Validate() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.m < MMin {
+		return errM()
+	}
+	if len(f.keys) < KMin {
+		return errK()
+	}
+	if !UniqueKeys(f.keys) {
+		return errUniqueKeys()
+	}
+	words := (f.m + 63) / 64
+	if uint64(len(f.bits)) != words {
+		return fmt.Errorf("%w and match the bits, which are %d word(s) rather than %d",
+			errM(), len(f.bits), words)
+	}
+	if tail := f.m % 64; tail != 0 && f.bits[words-1].Load()>>tail != 0 {
+		return errHash()
+	}
+	return nil
+}
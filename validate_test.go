@@ -0,0 +1,38 @@
+package bloomfilter
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	f, _ := New(1000, 5)
+	for _, x := range hashableUint64Values() {
+		f.Add(x)
+	}
+	if err := f.Validate(); err != nil {
+		t.Errorf("expected a valid filter, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(f *Filter)
+		err     error
+	}{
+		{"small m", func(f *Filter) { f.m = 1 }, errM()},
+		{"no keys", func(f *Filter) { f.keys = nil }, errK()},
+		{"repeated keys", func(f *Filter) { f.keys[1] = f.keys[0] }, errUniqueKeys()},
+		{"short bits", func(f *Filter) { f.bits = f.bits[:len(f.bits)-1] }, errM()},
+		{"long bits", func(f *Filter) { f.bits = make([]atomic.Uint64, 17) }, errM()},
+		{"padding bits", func(f *Filter) { f.bits[15].Or(1 << 63) }, errHash()},
+	}
+	for _, test := range tests {
+		f, _ := New(1000, 5)
+		test.corrupt(f)
+		err := f.Validate()
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}